@@ -39,12 +39,45 @@ function saveStoragePlatform(platform_uid, platform_token) {
 		key_token: platform_token,
 	});
 	chrome.storage.local.get(console.log);
+	sendCredentials({ platform_uid, platform_token });
 }
 function saveStorageMember(member_sid) {
 	chrome.storage.local.set({
 		key_sid: member_sid,
 	});
 	chrome.storage.local.get(console.log);
+	sendCredentials({ member_sid });
+}
+
+// TVerRec (Go版の serve) への送信
+async function callBridge(path, body) {
+	const { key_bridge_url, key_bridge_token } = await chrome.storage.local.get([
+		"key_bridge_url",
+		"key_bridge_token",
+	]);
+	if (!key_bridge_url || !key_bridge_token) {
+		throw new Error("TVerRecの接続先とペアリングトークンを設定してください");
+	}
+	const res = await fetch(`${key_bridge_url.replace(/\/+$/, "")}${path}`, {
+		method: "POST",
+		headers: {
+			"Content-Type": "application/json",
+			"X-Pairing-Token": key_bridge_token,
+		},
+		body: JSON.stringify(body),
+	});
+	const data = await res.json();
+	if (!res.ok) {
+		throw new Error(data.error || res.statusText);
+	}
+	return data;
+}
+
+// 取得した認証情報をTVerRecに送る (接続先が未設定なら何もしない)
+function sendCredentials(credentials) {
+	callBridge("/api/bridge/credentials", credentials)
+		.then(() => console.log("TVerRec Assistant: Credentials sent to TVerRec"))
+		.catch((e) => console.log(`TVerRec Assistant: ${e.message}`));
 }
 
 //クエリパラメータの処理
@@ -153,4 +186,24 @@ chrome.runtime.onMessage.addListener((message, sender, sendResponse) => {
 		enableMemberRule();
 		sendResponse({ status: true, data: "Memberルールを有効化しました" });
 	}
+	if (message.action === "sendCredentials") {
+		chrome.storage.local.get(null, ({ key_uid, key_token, key_sid }) => {
+			callBridge("/api/bridge/credentials", {
+				platform_uid: key_uid,
+				platform_token: key_token,
+				member_sid: key_sid,
+			})
+				.then(() => sendResponse({ status: true, data: "TVerRecに認証情報を送りました" }))
+				.catch((e) => sendResponse({ status: false, data: e.message }));
+		});
+		return true; // 非同期で応答する
+	}
+	if (message.action === "enqueue") {
+		callBridge("/api/bridge/enqueue", { url: message.data })
+			.then((data) =>
+				sendResponse({ status: true, data: `${data.jobs.length}件をダウンロードに追加しました` })
+			)
+			.catch((e) => sendResponse({ status: false, data: e.message }));
+		return true; // 非同期で応答する
+	}
 });
@@ -24,9 +24,59 @@ function readStorage() {
 	});
 }
 
-// ストレージのクリア
+// ストレージのクリア (TVerRecの接続設定は残す)
 function clearStorage() {
-	chrome.storage.local.clear();
+	chrome.storage.local.remove(["key_uid", "key_token", "key_sid"]);
+}
+
+// TVerRecとの連携状態を表示
+function showBridgeStatus(message) {
+	const statusElement = document.getElementById("bridge-status");
+	if (statusElement) {
+		statusElement.innerText = message;
+	}
+}
+
+// TVerRecの接続設定を読み込む
+function readBridgeSettings() {
+	const urlElement = document.getElementById("bridge-url");
+	const tokenElement = document.getElementById("bridge-token");
+	if (!urlElement || !tokenElement) {
+		return;
+	}
+	chrome.storage.local.get(["key_bridge_url", "key_bridge_token"], (data) => {
+		urlElement.value = data.key_bridge_url || "http://127.0.0.1:8080";
+		tokenElement.value = data.key_bridge_token || "";
+	});
+}
+
+// TVerRecの接続設定を保存して認証情報を送る
+const saveBridgeButton = document.getElementById("bridge-save");
+if (saveBridgeButton) {
+	saveBridgeButton.addEventListener("click", () => {
+		chrome.storage.local.set(
+			{
+				key_bridge_url: document.getElementById("bridge-url").value.trim(),
+				key_bridge_token: document.getElementById("bridge-token").value.trim(),
+			},
+			() => {
+				chrome.runtime.sendMessage({ action: "sendCredentials", data: "" }, (res) =>
+					showBridgeStatus(res.data)
+				);
+			}
+		);
+	});
+}
+
+// 開いているエピソード・シリーズをTVerRecでダウンロード
+const enqueueButton = document.getElementById("bridge-enqueue");
+if (enqueueButton) {
+	enqueueButton.addEventListener("click", async () => {
+		const [tab] = await chrome.tabs.query({ active: true, currentWindow: true });
+		chrome.runtime.sendMessage({ action: "enqueue", data: tab.url }, (res) =>
+			showBridgeStatus(res.data)
+		);
+	});
 }
 
 // ボタンクリック時に実行する処理を定義
@@ -58,3 +108,4 @@ document.getElementById("refresh").addEventListener("click", async () => {
 
 // ストレージからデータを読み込む
 readStorage();
+readBridgeSettings();
@@ -0,0 +1,87 @@
+// artwork.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// 動画と同じ名前のサムネイル画像のパス (Kodi/Jellyfinの <動画名>-thumb.jpg 形式)
+func thumbnailPath(mediaPath string) string {
+	return strings.TrimSuffix(mediaPath, filepath.Ext(mediaPath)) + "-thumb.jpg"
+}
+
+// エピソードのサムネイルを動画の隣に保存
+func (d *TVerDownloader) saveEpisodeThumbnail(mediaPath, url string) (string, error) {
+	client, err := d.tverClient()
+	if err != nil {
+		return "", err
+	}
+
+	path := thumbnailPath(mediaPath)
+	if err := client.DownloadImage(url, path); err != nil {
+		return "", fmt.Errorf("サムネイル保存エラー: %w", err)
+	}
+	return path, nil
+}
+
+// 番組のキービジュアルをposter.jpgとして保存 (既にあれば何もしない)
+func (d *TVerDownloader) SaveSeriesPoster(url string) error {
+	path := filepath.Join(d.OutputDir, "poster.jpg")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	client, err := d.tverClient()
+	if err != nil {
+		return err
+	}
+	if err := client.DownloadImage(url, path); err != nil {
+		return fmt.Errorf("番組画像保存エラー: %w", err)
+	}
+
+	fmt.Printf("番組画像を保存: %s\n", path)
+	return nil
+}
+
+// サムネイルをカバーアートとして動画に埋め込む
+func (d *TVerDownloader) embedThumbnail(mediaPath, thumbPath string) error {
+	ext := filepath.Ext(mediaPath)
+	tmpPath := strings.TrimSuffix(mediaPath, ext) + ".cover" + ext
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", mediaPath}
+	switch ext {
+	case ".mp4", ".m4v":
+		args = append(args,
+			"-i", thumbPath,
+			"-map", "0", "-map", "1", "-c", "copy",
+			"-disposition:v:1", "attached_pic",
+		)
+	case ".mkv":
+		args = append(args,
+			"-map", "0", "-c", "copy",
+			"-attach", thumbPath,
+			"-metadata:s:t", "mimetype=image/jpeg",
+			"-metadata:s:t", "filename=cover.jpg",
+		)
+	default:
+		return fmt.Errorf("%s にはカバーアートを埋め込めません", ext)
+	}
+	args = append(args, tmpPath)
+
+	if output, err := exec.Command(d.FfmpegPath, args...).CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpegカバーアート埋め込みエラー: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	if err := os.Rename(tmpPath, mediaPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ファイル置換エラー: %w", err)
+	}
+
+	fmt.Printf("カバーアートを埋め込み: %s\n", filepath.Base(mediaPath))
+	return nil
+}
@@ -0,0 +1,47 @@
+// artwork_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThumbnailPath(t *testing.T) {
+	cases := map[string]string{
+		"out/番組 - 第1話.mp4":   "out/番組 - 第1話-thumb.jpg",
+		"out/番組 - 第1.5話.mkv": "out/番組 - 第1.5話-thumb.jpg",
+		"a":                  "a-thumb.jpg",
+	}
+	for mediaPath, want := range cases {
+		if got := thumbnailPath(mediaPath); got != want {
+			t.Errorf("thumbnailPath(%q) = %q, want %q", mediaPath, got, want)
+		}
+	}
+}
+
+func TestSaveSeriesPosterKeepsExisting(t *testing.T) {
+	dir := t.TempDir()
+	posterPath := filepath.Join(dir, "poster.jpg")
+	writeTestFile(t, posterPath, "既存の画像")
+
+	// 既にあればAPIクライアントを作らずに終わる
+	d := NewTVerDownloader(dir)
+	if err := d.SaveSeriesPoster("https://statics.tver.jp/images/content/thumbnail/series/xlarge/sr1.jpg"); err != nil {
+		t.Fatalf("SaveSeriesPoster: %v", err)
+	}
+	if d.Client != nil {
+		t.Error("APIクライアントが作成されました")
+	}
+	if data, _ := os.ReadFile(posterPath); string(data) != "既存の画像" {
+		t.Errorf("poster.jpg が上書きされました: %s", data)
+	}
+}
+
+func TestEmbedThumbnailUnsupported(t *testing.T) {
+	d := NewTVerDownloader(t.TempDir())
+	d.FfmpegPath = "存在しないffmpeg"
+	if err := d.embedThumbnail("a.ts", "a-thumb.jpg"); err == nil {
+		t.Error("tsへのカバーアート埋め込みがエラーになっていません")
+	}
+}
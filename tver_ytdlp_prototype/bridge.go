@@ -0,0 +1,210 @@
+// bridge.go
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ブラウザ拡張機能(TVerRec Assistant)がペアリングトークンを送るヘッダー
+const pairingTokenHeader = "X-Pairing-Token"
+
+// ブラウザ拡張機能との連携設定 (認証情報を含むため所有者のみ読み書き可能で保存)
+type BridgeConfig struct {
+	PairingToken  string    `json:"pairing_token"`
+	PlatformUID   string    `json:"platform_uid,omitempty"`
+	PlatformToken string    `json:"platform_token,omitempty"`
+	MemberSID     string    `json:"member_sid,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at,omitempty"` // 認証情報を最後に受け取った日時
+}
+
+// 連携設定を読み込み (存在しなければ空の設定を返す)
+func LoadBridgeConfig(path string) (*BridgeConfig, error) {
+	config := &BridgeConfig{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("連携設定読み込みエラー: %w", err)
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("連携設定解析エラー: %w", err)
+	}
+	return config, nil
+}
+
+// 連携設定を読み込み、ペアリングトークンがなければ発行して保存 (発行した場合はissuedがtrue)
+func loadOrPairBridgeConfig(path string) (config *BridgeConfig, issued bool, err error) {
+	config, err = LoadBridgeConfig(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if config.PairingToken != "" {
+		return config, false, nil
+	}
+
+	if config.PairingToken, err = newPairingToken(); err != nil {
+		return nil, false, err
+	}
+	if err := config.Save(path); err != nil {
+		return nil, false, err
+	}
+	return config, true, nil
+}
+
+// 連携設定を保存 (一時ファイル経由で置き換え)
+func (c *BridgeConfig) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("JSON生成エラー: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("設定ディレクトリ作成エラー: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("連携設定書き込みエラー: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("連携設定書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// マイページ用の認証情報があるか
+func (c *BridgeConfig) HasUserCredentials() bool {
+	return c.MemberSID != "" || (c.PlatformUID != "" && c.PlatformToken != "")
+}
+
+// ランダムなペアリングトークンを発行
+func newPairingToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("ペアリングトークン生成エラー: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// リクエストのペアリングトークンを確認し、不一致ならエラーを返してfalse
+func (s *DownloadServer) checkPairingToken(w http.ResponseWriter, r *http.Request) bool {
+	if s.bridge == nil {
+		writeJSONError(w, http.StatusNotFound, errors.New("ブラウザ拡張機能との連携は無効です"))
+		return false
+	}
+	if !s.validPairingToken(r) {
+		writeJSONError(w, http.StatusUnauthorized, errors.New("ペアリングトークンが一致しません"))
+		return false
+	}
+	return true
+}
+
+// リクエストに正しいペアリングトークンが付いているか (X-Pairing-Token または Authorization: Bearer)
+func (s *DownloadServer) validPairingToken(r *http.Request) bool {
+	if s.bridge == nil {
+		return false
+	}
+
+	token := r.Header.Get(pairingTokenHeader)
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+
+	s.mu.Lock()
+	expected := s.bridge.PairingToken
+	s.mu.Unlock()
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// 拡張機能から受け取る認証情報
+type bridgeCredentials struct {
+	PlatformUID   string `json:"platform_uid"`
+	PlatformToken string `json:"platform_token"`
+	MemberSID     string `json:"member_sid"`
+}
+
+// POST /api/bridge/credentials: 拡張機能が取得したマイページ用の認証情報を保存
+func (s *DownloadServer) handleBridgeCredentials(w http.ResponseWriter, r *http.Request) {
+	if !s.checkPairingToken(w, r) {
+		return
+	}
+
+	var creds bridgeCredentials
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("リクエスト解析エラー: %w", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 拡張機能はplatform_uid/platform_tokenとmember_sidを別々に取得するため、送られたものだけ更新する
+	updated := *s.bridge
+	if creds.PlatformUID != "" || creds.PlatformToken != "" {
+		if creds.PlatformUID == "" || creds.PlatformToken == "" {
+			writeJSONError(w, http.StatusBadRequest, errors.New("platform_uidとplatform_tokenは両方を指定してください"))
+			return
+		}
+		updated.PlatformUID = creds.PlatformUID
+		updated.PlatformToken = creds.PlatformToken
+	}
+	if creds.MemberSID != "" {
+		updated.MemberSID = creds.MemberSID
+	}
+	if !updated.HasUserCredentials() {
+		writeJSONError(w, http.StatusBadRequest, errors.New("platform_uidとplatform_token、またはmember_sidを指定してください"))
+		return
+	}
+	updated.UpdatedAt = time.Now()
+
+	if err := updated.Save(s.opts.BridgeConfigPath); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	*s.bridge = updated
+	// 実行中のジョブが使っているクライアントは変更せず、認証情報を設定した複製と差し替える
+	if s.downloader.Client != nil {
+		client := *s.downloader.Client
+		client.SetUserCredentials(updated.PlatformUID, updated.PlatformToken, updated.MemberSID)
+		s.downloader.Client = &client
+	}
+	fmt.Println("ブラウザ拡張機能からマイページ用の認証情報を受け取りました")
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"has_platform": updated.PlatformUID != "",
+		"has_member":   updated.MemberSID != "",
+		"updated_at":   updated.UpdatedAt,
+	})
+}
+
+// POST /api/bridge/enqueue: 拡張機能で開いているエピソード・シリーズを登録
+func (s *DownloadServer) handleBridgeEnqueue(w http.ResponseWriter, r *http.Request) {
+	if !s.checkPairingToken(w, r) {
+		return
+	}
+
+	var req enqueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("リクエスト解析エラー: %w", err))
+		return
+	}
+
+	jobs, err := s.enqueueURL(req)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"jobs": jobs})
+}
@@ -0,0 +1,703 @@
+// catalog.go
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// ダウンロード試行の結果
+const (
+	downloadSucceeded = "succeeded"
+	downloadFailed    = "failed"
+	downloadSkipped   = "skipped" // ダウンロード対象外などでダウンロードしなかった
+)
+
+// 検証状態 (PowerShell版の videoValidated と同じ値)
+const (
+	validationPending = 0 // 未検証
+	validationOK      = 1 // 検証済
+	validationRunning = 2 // 検証中
+	validationFailed  = 3 // 検証失敗
+)
+
+// 他のプロセスがカタログに書き込み中の場合に待つ時間 (ミリ秒)
+const catalogBusyTimeoutMS = 10000
+
+// カタログ上でダウンロード済みのエピソードを再度ダウンロードしようとした
+var ErrAlreadyDownloaded = errors.New("ダウンロード済みです")
+
+// 番組・シーズン・エピソードとダウンロード試行の記録 (SQLite)
+//
+// serve と CLI など複数のプロセスから同時に開いても、書き込みはSQLiteのロックで直列化される。
+// スキーマの変更は catalogMigrations で段階的に移行する。
+type Catalog struct {
+	db   *sql.DB
+	path string
+}
+
+// 番組
+type CatalogSeries struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Broadcaster string    `json:"broadcaster,omitempty"`
+	Description string    `json:"description,omitempty"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// シーズン
+type CatalogSeason struct {
+	ID       string `json:"id"`
+	SeriesID string `json:"series_id"`
+	Title    string `json:"title"`
+	Index    int    `json:"index,omitempty"` // シリーズ内の順番 (1始まり, 0は不明)
+}
+
+// エピソード
+type CatalogEpisode struct {
+	ID            string    `json:"id"`
+	SeriesID      string    `json:"series_id,omitempty"`
+	SeasonID      string    `json:"season_id,omitempty"`
+	Title         string    `json:"title"`
+	SeriesTitle   string    `json:"series_title,omitempty"`
+	SeasonTitle   string    `json:"season_title,omitempty"`
+	EpisodeNumber int       `json:"episode_number,omitempty"`
+	Broadcaster   string    `json:"broadcaster,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	BroadcastDate string    `json:"broadcast_date,omitempty"` // 放送日の表記 (例: 4月2日(火)放送)
+	EndAt         int64     `json:"end_at,omitempty"`         // 配信終了日時 (UNIX秒, 0は不明)
+	Version       int       `json:"version,omitempty"`
+	Genre         string    `json:"genre,omitempty"` // PowerShell版で取得元となったキーワード
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	VersionCheckedAt time.Time `json:"-"` // 新しいバージョンが公開されていないか最後に確認した日時
+}
+
+// ダウンロード試行
+type CatalogDownload struct {
+	ID         int       `json:"id"`
+	EpisodeID  string    `json:"episode_id"`
+	URL        string    `json:"url"`
+	SeriesURL  string    `json:"series_url,omitempty"`
+	Genre      string    `json:"genre,omitempty"` // PowerShell版で取得元となったキーワード
+	Status     string    `json:"status"`          // succeeded, failed, skipped
+	Error      string    `json:"error,omitempty"`
+	Validated  int       `json:"validated"`            // 検証状態
+	VideoDir   string    `json:"video_dir,omitempty"`  // 保存先ディレクトリ
+	VideoName  string    `json:"video_name,omitempty"` // 動画のファイル名
+	VideoPath  string    `json:"video_path,omitempty"` // 動画のパス (PowerShell版から取り込んだものは保存先からの相対パス)
+	Bytes      int64     `json:"bytes,omitempty"`
+	Version    int       `json:"version,omitempty"` // ダウンロードした番組のバージョン (0は不明)
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Source     string    `json:"source,omitempty"` // 取り込み元 (空ならこのツールでのダウンロード)
+
+	// ダウンロードした時点の番組情報 (history.csv の同名の列、エピソード情報が後で更新されても変わらない)
+	Series        string `json:"series,omitempty"`
+	Season        string `json:"season,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Media         string `json:"media,omitempty"`
+	BroadcastDate string `json:"broadcast_date,omitempty"`
+}
+
+// 各バージョンから次のバージョンへの移行 (catalogMigrations[i] でバージョンiからi+1へ)
+// 適用済みのバージョンは PRAGMA user_version に記録する。日時はUNIXナノ秒 (0は不明)。
+var catalogMigrations = []string{
+	// 0 → 1: 初期スキーマ
+	`CREATE TABLE series (
+		id          TEXT PRIMARY KEY,
+		title       TEXT NOT NULL DEFAULT '',
+		broadcaster TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		image_url   TEXT NOT NULL DEFAULT '',
+		updated_at  INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE seasons (
+		id        TEXT PRIMARY KEY,
+		series_id TEXT NOT NULL DEFAULT '',
+		title     TEXT NOT NULL DEFAULT '',
+		idx       INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE episodes (
+		id                 TEXT PRIMARY KEY,
+		series_id          TEXT NOT NULL DEFAULT '',
+		season_id          TEXT NOT NULL DEFAULT '',
+		title              TEXT NOT NULL DEFAULT '',
+		series_title       TEXT NOT NULL DEFAULT '',
+		season_title       TEXT NOT NULL DEFAULT '',
+		episode_number     INTEGER NOT NULL DEFAULT 0,
+		broadcaster        TEXT NOT NULL DEFAULT '',
+		description        TEXT NOT NULL DEFAULT '',
+		broadcast_date     TEXT NOT NULL DEFAULT '',
+		end_at             INTEGER NOT NULL DEFAULT 0,
+		version            INTEGER NOT NULL DEFAULT 0,
+		genre              TEXT NOT NULL DEFAULT '',
+		updated_at         INTEGER NOT NULL DEFAULT 0,
+		version_checked_at INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX episodes_series ON episodes (series_id);
+	CREATE TABLE downloads (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		episode_id     TEXT NOT NULL,
+		url            TEXT NOT NULL DEFAULT '',
+		series_url     TEXT NOT NULL DEFAULT '',
+		genre          TEXT NOT NULL DEFAULT '',
+		status         TEXT NOT NULL,
+		error          TEXT NOT NULL DEFAULT '',
+		validated      INTEGER NOT NULL DEFAULT 0,
+		video_dir      TEXT NOT NULL DEFAULT '',
+		video_name     TEXT NOT NULL DEFAULT '',
+		video_path     TEXT NOT NULL DEFAULT '',
+		bytes          INTEGER NOT NULL DEFAULT 0,
+		version        INTEGER NOT NULL DEFAULT 0,
+		started_at     INTEGER NOT NULL DEFAULT 0,
+		finished_at    INTEGER NOT NULL DEFAULT 0,
+		source         TEXT NOT NULL DEFAULT '',
+		series         TEXT NOT NULL DEFAULT '',
+		season         TEXT NOT NULL DEFAULT '',
+		title          TEXT NOT NULL DEFAULT '',
+		media          TEXT NOT NULL DEFAULT '',
+		broadcast_date TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX downloads_episode ON downloads (episode_id, finished_at, id);
+	CREATE INDEX downloads_finished ON downloads (finished_at);
+	CREATE UNIQUE INDEX downloads_imported ON downloads (source, url, finished_at) WHERE source <> '';`,
+}
+
+// 各表の列 (scan〜 関数の引数と同じ順)
+const (
+	catalogSeriesColumns   = "id, title, broadcaster, description, image_url, updated_at"
+	catalogEpisodeColumns  = "id, series_id, season_id, title, series_title, season_title, episode_number, broadcaster, description, broadcast_date, end_at, version, genre, updated_at, version_checked_at"
+	catalogDownloadColumns = "id, episode_id, url, series_url, genre, status, error, validated, video_dir, video_name, video_path, bytes, version, started_at, finished_at, source, series, season, title, media, broadcast_date"
+)
+
+// エピソード (別名 e) ごとに最新のダウンロード試行を別名 l で結合する
+const latestDownloadJoin = `LEFT JOIN downloads l ON l.id = (
+	SELECT id FROM downloads WHERE episode_id = e.id ORDER BY finished_at DESC, id DESC LIMIT 1)`
+
+// カタログを開く (存在しなければ作成し、古いスキーマは移行する)
+func OpenCatalog(path string) (*Catalog, error) {
+	dsn := fmt.Sprintf("%s?_pragma=busy_timeout(%d)&_pragma=journal_mode(WAL)&_txlock=immediate", path, catalogBusyTimeoutMS)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("カタログ読み込みエラー: %w", err)
+	}
+	c := &Catalog{db: db, path: path}
+	if err := c.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// カタログを閉じる
+func (c *Catalog) Close() error {
+	return c.db.Close()
+}
+
+// 未適用の移行をバージョン順に1つずつ適用
+func (c *Catalog) migrate() error {
+	for {
+		tx, err := c.db.Begin()
+		if err != nil {
+			return fmt.Errorf("カタログ読み込みエラー: %w", err)
+		}
+		// 他のプロセスが先に移行していることがあるため、ロックを取ってから確認する
+		var version int
+		if err := tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("カタログ読み込みエラー: %w", err)
+		}
+		if version > len(catalogMigrations) {
+			tx.Rollback()
+			return fmt.Errorf("カタログ %s は新しいバージョン(%d)で作成されています", c.path, version)
+		}
+		if version == len(catalogMigrations) {
+			return tx.Rollback()
+		}
+
+		if _, err := tx.Exec(catalogMigrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("カタログ移行エラー (バージョン%d): %w", version, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("カタログ移行エラー (バージョン%d): %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("カタログ移行エラー (バージョン%d): %w", version, err)
+		}
+	}
+}
+
+// 1つのトランザクション内のカタログ操作
+type catalogTx struct {
+	*sql.Tx
+}
+
+// トランザクション内で更新 (fnがエラーを返したら取り消す)
+func (c *Catalog) update(fn func(tx catalogTx) error) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	if err := fn(catalogTx{tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// 番組を登録 (overwriteがfalseなら登録済みのものは変更しない)
+func (tx catalogTx) putSeries(series CatalogSeries, overwrite bool) error {
+	conflict := "DO NOTHING"
+	if overwrite {
+		conflict = `DO UPDATE SET title = excluded.title, broadcaster = excluded.broadcaster,
+			description = excluded.description, image_url = excluded.image_url, updated_at = excluded.updated_at`
+	}
+	_, err := tx.Exec(`INSERT INTO series (`+catalogSeriesColumns+`) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) `+conflict,
+		series.ID, series.Title, series.Broadcaster, series.Description, series.ImageURL, catalogTime(series.UpdatedAt))
+	if err != nil {
+		return fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// シーズンを登録 (overwriteがfalseなら登録済みのものは変更しない)
+func (tx catalogTx) putSeason(season CatalogSeason, overwrite bool) error {
+	conflict := "DO NOTHING"
+	if overwrite {
+		conflict = "DO UPDATE SET series_id = excluded.series_id, title = excluded.title, idx = excluded.idx"
+	}
+	_, err := tx.Exec(`INSERT INTO seasons (id, series_id, title, idx) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) `+conflict,
+		season.ID, season.SeriesID, season.Title, season.Index)
+	if err != nil {
+		return fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// エピソードを取得し、なければ空のエピソードを返す
+func (tx catalogTx) episode(id string) (*CatalogEpisode, error) {
+	episode, err := scanCatalogEpisode(tx.QueryRow(`SELECT `+catalogEpisodeColumns+` FROM episodes WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return &CatalogEpisode{ID: id}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("カタログ読み込みエラー: %w", err)
+	}
+	return &episode, nil
+}
+
+// エピソードを登録・更新
+func (tx catalogTx) putEpisode(e *CatalogEpisode) error {
+	_, err := tx.Exec(`INSERT OR REPLACE INTO episodes (`+catalogEpisodeColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID, e.SeriesID, e.SeasonID, e.Title, e.SeriesTitle, e.SeasonTitle, e.EpisodeNumber,
+		e.Broadcaster, e.Description, e.BroadcastDate, e.EndAt, e.Version, e.Genre, catalogTime(e.UpdatedAt),
+		catalogTime(e.VersionCheckedAt))
+	if err != nil {
+		return fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// ダウンロード試行を追加 (IDは採番する)
+// 同じ取り込み元・URL・日時の行を取り込み済みなら追加せずfalseを返す
+func (tx catalogTx) insertDownload(d CatalogDownload) (bool, error) {
+	res, err := tx.Exec(`INSERT INTO downloads (`+catalogDownloadColumns+`)
+		VALUES (NULL, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		d.EpisodeID, d.URL, d.SeriesURL, d.Genre, d.Status, d.Error, d.Validated,
+		d.VideoDir, d.VideoName, d.VideoPath, d.Bytes, d.Version,
+		catalogTime(d.StartedAt), catalogTime(d.FinishedAt), d.Source,
+		d.Series, d.Season, d.Title, d.Media, d.BroadcastDate)
+	if err != nil {
+		return false, fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	return inserted > 0, nil
+}
+
+// 日時を列の値に変換 (UNIXナノ秒, ゼロ値は0)
+func catalogTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// 列の値を日時に変換
+func parseCatalogTime(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// QueryRow と Rows の共通部分
+type catalogScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCatalogSeries(row catalogScanner) (CatalogSeries, error) {
+	var s CatalogSeries
+	var updatedAt int64
+	err := row.Scan(&s.ID, &s.Title, &s.Broadcaster, &s.Description, &s.ImageURL, &updatedAt)
+	s.UpdatedAt = parseCatalogTime(updatedAt)
+	return s, err
+}
+
+func scanCatalogEpisode(row catalogScanner, extra ...any) (CatalogEpisode, error) {
+	var e CatalogEpisode
+	var updatedAt, versionCheckedAt int64
+	dest := []any{&e.ID, &e.SeriesID, &e.SeasonID, &e.Title, &e.SeriesTitle, &e.SeasonTitle, &e.EpisodeNumber,
+		&e.Broadcaster, &e.Description, &e.BroadcastDate, &e.EndAt, &e.Version, &e.Genre, &updatedAt, &versionCheckedAt}
+	err := row.Scan(append(dest, extra...)...)
+	e.UpdatedAt, e.VersionCheckedAt = parseCatalogTime(updatedAt), parseCatalogTime(versionCheckedAt)
+	return e, err
+}
+
+func scanCatalogDownload(row catalogScanner, extra ...any) (CatalogDownload, error) {
+	var d CatalogDownload
+	var startedAt, finishedAt int64
+	dest := []any{&d.ID, &d.EpisodeID, &d.URL, &d.SeriesURL, &d.Genre, &d.Status, &d.Error, &d.Validated,
+		&d.VideoDir, &d.VideoName, &d.VideoPath, &d.Bytes, &d.Version, &startedAt, &finishedAt, &d.Source,
+		&d.Series, &d.Season, &d.Title, &d.Media, &d.BroadcastDate}
+	err := row.Scan(append(dest, extra...)...)
+	d.StartedAt, d.FinishedAt = parseCatalogTime(startedAt), parseCatalogTime(finishedAt)
+	return d, err
+}
+
+// 列名に表の別名を付ける
+func qualifyColumns(alias, columns string) string {
+	names := strings.Split(columns, ",")
+	for i, name := range names {
+		names[i] = alias + "." + strings.TrimSpace(name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// 読み込み専用の操作のエラーを記録 (呼び出し元は記録がないものとして続ける)
+func logCatalogError(err error) {
+	log.Printf("カタログ読み込みエラー: %v", err)
+}
+
+// シリーズ情報とエピソード一覧を登録・更新
+func (c *Catalog) UpsertSeries(info *SeriesInfo, episodes []ParsedEpisode) error {
+	now := time.Now()
+	return c.update(func(tx catalogTx) error {
+		err := tx.putSeries(CatalogSeries{
+			ID:          info.ID,
+			Title:       info.Title,
+			Broadcaster: info.Broadcaster,
+			Description: info.Description,
+			ImageURL:    info.ImageURL,
+			UpdatedAt:   now,
+		}, true)
+		if err != nil {
+			return err
+		}
+		for i, season := range info.Seasons {
+			if err := tx.putSeason(CatalogSeason{ID: season.ID, SeriesID: info.ID, Title: season.Title, Index: i + 1}, true); err != nil {
+				return err
+			}
+		}
+
+		for _, ep := range episodes {
+			episode, err := tx.episode(ep.ID)
+			if err != nil {
+				return err
+			}
+			episode.SeriesID = info.ID
+			episode.SeriesTitle = info.Title
+			episode.Title = ep.Title
+			if ep.SeasonID != "" {
+				episode.SeasonID = ep.SeasonID
+				episode.SeasonTitle = ep.SeasonTitle
+			}
+			if ep.EpisodeNumber > 0 {
+				episode.EpisodeNumber = ep.EpisodeNumber
+			}
+			if episode.Broadcaster == "" {
+				episode.Broadcaster = info.Broadcaster
+			}
+			if !ep.EndAt.IsZero() {
+				episode.EndAt = ep.EndAt.Unix()
+			}
+			episode.UpdatedAt = now
+			if err := tx.putEpisode(episode); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// エピソードの詳細情報を登録・更新
+func (c *Catalog) UpsertEpisodeDetail(detail *EpisodeDetail) error {
+	return c.update(func(tx catalogTx) error {
+		episode, err := tx.episode(detail.ID)
+		if err != nil {
+			return err
+		}
+		episode.SeriesID = detail.SeriesID
+		episode.SeasonID = detail.SeasonID
+		episode.Title = detail.Title
+		episode.SeriesTitle = detail.SeriesTitle
+		episode.SeasonTitle = detail.SeasonTitle
+		episode.EpisodeNumber = detail.EpisodeNumber
+		episode.Broadcaster = detail.Broadcaster
+		episode.Description = detail.Description
+		episode.BroadcastDate = detail.BroadcastLabel
+		episode.EndAt = detail.EndAt
+		episode.Version = detail.Version
+		episode.UpdatedAt = time.Now()
+		if err := tx.putEpisode(episode); err != nil {
+			return err
+		}
+
+		if detail.SeriesID != "" {
+			err := tx.putSeries(CatalogSeries{
+				ID:          detail.SeriesID,
+				Title:       detail.SeriesTitle,
+				Broadcaster: detail.Broadcaster,
+				ImageURL:    detail.SeriesImageURL,
+				UpdatedAt:   episode.UpdatedAt,
+			}, false)
+			if err != nil {
+				return err
+			}
+		}
+		if detail.SeasonID != "" {
+			return tx.putSeason(CatalogSeason{ID: detail.SeasonID, SeriesID: detail.SeriesID, Title: detail.SeasonTitle}, false)
+		}
+		return nil
+	})
+}
+
+// ダウンロード試行を記録
+func (c *Catalog) RecordDownload(download CatalogDownload) error {
+	return c.update(func(tx catalogTx) error {
+		_, err := tx.insertDownload(download)
+		return err
+	})
+}
+
+// エピソードの最新のダウンロード試行
+func (c *Catalog) LatestDownload(episodeID string) (CatalogDownload, bool) {
+	download, err := scanCatalogDownload(c.db.QueryRow(`SELECT `+catalogDownloadColumns+` FROM downloads
+		WHERE episode_id = ? ORDER BY finished_at DESC, id DESC LIMIT 1`, episodeID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return CatalogDownload{}, false
+	}
+	if err != nil {
+		logCatalogError(err)
+		return CatalogDownload{}, false
+	}
+	return download, true
+}
+
+// 再ダウンロード不要か (最新の試行が成功かつ検証失敗でない、またはダウンロード対象外)
+func (c *Catalog) IsDownloaded(episodeID string) bool {
+	latest, ok := c.LatestDownload(episodeID)
+	if !ok {
+		return false
+	}
+	switch latest.Status {
+	case downloadSucceeded:
+		return latest.Validated != validationFailed
+	case downloadSkipped:
+		return true
+	}
+	return false
+}
+
+// シリーズごとの集計
+type CatalogSeriesSummary struct {
+	Series     CatalogSeries
+	Episodes   int // カタログにあるエピソード数
+	Downloaded int // ダウンロード済みのエピソード数
+	Failed     int // 最新の試行が失敗したエピソード数
+}
+
+// シリーズごとにエピソード数とダウンロード状況を集計 (番組名順)
+func (c *Catalog) SeriesSummaries() []CatalogSeriesSummary {
+	summaries := make(map[string]*CatalogSeriesSummary)
+	rows, err := c.db.Query(`SELECT ` + catalogSeriesColumns + ` FROM series`)
+	if err != nil {
+		logCatalogError(err)
+		return nil
+	}
+	for rows.Next() {
+		series, err := scanCatalogSeries(rows)
+		if err != nil {
+			rows.Close()
+			logCatalogError(err)
+			return nil
+		}
+		summaries[series.ID] = &CatalogSeriesSummary{Series: series}
+	}
+	rows.Close()
+
+	rows, err = c.db.Query(`SELECT e.series_id, MAX(e.series_title), COUNT(*),
+			COUNT(CASE WHEN l.status = ? THEN 1 END), COUNT(CASE WHEN l.status = ? THEN 1 END)
+		FROM episodes e `+latestDownloadJoin+` GROUP BY e.series_id`, downloadSucceeded, downloadFailed)
+	if err != nil {
+		logCatalogError(err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seriesID, seriesTitle string
+		var episodes, downloaded, failed int
+		if err := rows.Scan(&seriesID, &seriesTitle, &episodes, &downloaded, &failed); err != nil {
+			logCatalogError(err)
+			return nil
+		}
+		summary, ok := summaries[seriesID]
+		if !ok {
+			summary = &CatalogSeriesSummary{Series: CatalogSeries{ID: seriesID, Title: seriesTitle}}
+			summaries[seriesID] = summary
+		}
+		summary.Episodes, summary.Downloaded, summary.Failed = episodes, downloaded, failed
+	}
+
+	result := make([]CatalogSeriesSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Series.Title < result[j].Series.Title
+	})
+	return result
+}
+
+// シリーズのエピソード (シーズン順・話数順)
+func (c *Catalog) SeriesEpisodes(seriesID string) []CatalogEpisode {
+	rows, err := c.db.Query(`SELECT `+qualifyColumns("e", catalogEpisodeColumns)+` FROM episodes e
+		LEFT JOIN seasons s ON s.id = e.season_id
+		WHERE e.series_id = ? ORDER BY COALESCE(s.idx, 0), e.episode_number, e.id`, seriesID)
+	if err != nil {
+		logCatalogError(err)
+		return nil
+	}
+	defer rows.Close()
+
+	var episodes []CatalogEpisode
+	for rows.Next() {
+		episode, err := scanCatalogEpisode(rows)
+		if err != nil {
+			logCatalogError(err)
+			return nil
+		}
+		episodes = append(episodes, episode)
+	}
+	return episodes
+}
+
+// カタログの一覧を表示 (seriesIDが空ならシリーズごとの集計)
+func (c *Catalog) Display(seriesID string) {
+	if seriesID == "" {
+		summaries := c.SeriesSummaries()
+		fmt.Printf("\n=== カタログ: %d番組 ===\n", len(summaries))
+		for _, summary := range summaries {
+			if summary.Series.ID == "" {
+				summary.Series.Title = "(番組不明)"
+			}
+			fmt.Printf("%s (%s): %d話 / ダウンロード済 %d話", summary.Series.Title, summary.Series.ID, summary.Episodes, summary.Downloaded)
+			if summary.Failed > 0 {
+				fmt.Printf(" / 失敗 %d話", summary.Failed)
+			}
+			fmt.Println()
+		}
+		fmt.Println("==================")
+		return
+	}
+
+	episodes := c.SeriesEpisodes(seriesID)
+	fmt.Printf("\n=== カタログ: %s (%d話) ===\n", seriesID, len(episodes))
+	for _, episode := range episodes {
+		status := "未ダウンロード"
+		if latest, ok := c.LatestDownload(episode.ID); ok {
+			status = latest.Status
+			if latest.VideoPath != "" {
+				status += " " + latest.VideoPath
+			}
+		}
+		fmt.Printf("%s %s [%s]\n", episode.ID, episode.Title, status)
+		if episode.EndAt != 0 {
+			fmt.Printf("    配信終了: %s\n", formatEndAt(time.Unix(episode.EndAt, 0)))
+		}
+	}
+	fmt.Println("==================")
+}
+
+// ダウンロード結果をカタログに記録 (カタログ未設定なら何もしない)
+func (d *TVerDownloader) recordDownload(url string, startedAt time.Time, result *DownloadResult, err error) {
+	if d.Catalog == nil || errors.Is(err, ErrAlreadyDownloaded) {
+		return
+	}
+	episodeID, idErr := extractEpisodeID(url)
+	if idErr != nil {
+		return
+	}
+
+	download := CatalogDownload{
+		EpisodeID:  episodeID,
+		URL:        url,
+		Status:     downloadSucceeded,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+	if err != nil {
+		download.Status = downloadFailed
+		download.Error = err.Error()
+	}
+	if result != nil {
+		download.Validated = validationOK
+		download.VideoDir = filepath.Dir(result.MediaPath)
+		download.VideoName = filepath.Base(result.MediaPath)
+		download.VideoPath = result.MediaPath
+		if info, statErr := os.Stat(result.MediaPath); statErr == nil {
+			download.Bytes = info.Size()
+		}
+		if result.Detail != nil {
+			download.Version = result.Detail.Version
+			download.setDetail(result.Detail)
+			if result.Detail.SeriesID != "" {
+				download.SeriesURL = fmt.Sprintf("https://tver.jp/series/%s", result.Detail.SeriesID)
+			}
+		}
+	}
+	if recordErr := d.Catalog.RecordDownload(download); recordErr != nil {
+		fmt.Printf("カタログ記録エラー: %v\n", recordErr)
+	}
+}
+
+// ダウンロードした時点の番組情報を記録
+func (d *CatalogDownload) setDetail(detail *EpisodeDetail) {
+	d.Series = detail.SeriesTitle
+	d.Season = detail.SeasonTitle
+	d.Title = detail.Title
+	d.Media = detail.Broadcaster
+	d.BroadcastDate = detail.BroadcastLabel
+}
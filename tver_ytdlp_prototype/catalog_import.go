@@ -0,0 +1,299 @@
+// catalog_import.go
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PowerShell版のダウンロード日時の書式 (Get-TimeStamp)
+const historyTimeLayout = "2006-01-02 15:04:05"
+
+// PowerShell版がダウンロード対象外として記録するファイル名
+var historySkippedNames = map[string]bool{
+	"-- SKIPPED --": true,
+	"-- IGNORED --": true,
+}
+
+// list.csv の endTime の書式 (Export-Csv は実行環境のカルチャで日時を書き出す)
+var listEndTimeLayouts = []string{
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"1/2/2006 3:04:05 PM",
+}
+
+// PowerShell版の日時は日本時間で書き出されている
+var jst = time.FixedZone("JST", 9*60*60)
+
+// 取り込み結果
+type CatalogImportResult struct {
+	Format    string // history または list
+	Episodes  int    // 登録・更新したエピソード数
+	Downloads int    // 追加したダウンロード試行数
+	Duplicate int    // 取り込み済みのため追加しなかった行数
+	Invalid   int    // 解析できず読み飛ばした行数
+}
+
+// history.csv・list.csv をヘッダーで判別してカタログに取り込む
+func (c *Catalog) ImportFile(path string) (*CatalogImportResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("取り込みファイル読み込みエラー: %w", err)
+	}
+	defer f.Close()
+
+	header, rows, dropped, err := readCSVRecords(f)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.importRecords(header, rows, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	result.Invalid += dropped
+	return result, nil
+}
+
+// CSVのヘッダーと、各行を列名→値のマップとして読み込む
+// (書き込み中断などでNULL文字が混入した行は除き、その行数を返す)
+func readCSVRecords(r io.Reader) ([]string, []map[string]string, int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("CSV読み込みエラー: %w", err)
+	}
+	// BOMは引用符で囲まれた先頭の列名の前に付くため、CSVとして読む前に除く
+	lines := strings.SplitAfter(strings.TrimPrefix(string(data), "\uFEFF"), "\n")
+	cleaned := slices.DeleteFunc(lines, func(line string) bool {
+		return strings.ContainsRune(line, 0)
+	})
+	dropped := len(lines) - len(cleaned)
+
+	reader := csv.NewReader(strings.NewReader(strings.Join(cleaned, "")))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("CSVヘッダー読み込みエラー: %w", err)
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("CSV解析エラー: %w", err)
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return header, rows, dropped, nil
+}
+
+// 取り込みは1つのトランザクションで行い、途中でエラーになったら何も取り込まない
+func (c *Catalog) importRecords(header []string, rows []map[string]string, source string) (*CatalogImportResult, error) {
+	result := &CatalogImportResult{}
+
+	var importRow func(tx catalogTx, row map[string]string) error
+	switch {
+	case slices.Contains(header, "videoPage"):
+		result.Format = "history"
+		importRow = func(tx catalogTx, row map[string]string) error {
+			record, ok := historyRecordFromRow(row)
+			if !ok {
+				result.Invalid++
+				return nil
+			}
+			return tx.importHistoryRecord(record, source, result)
+		}
+	case slices.Contains(header, "episodeID"):
+		result.Format = "list"
+		importRow = func(tx catalogTx, row map[string]string) error {
+			return tx.importListRow(row, result)
+		}
+	default:
+		return nil, errors.New("history.csv・list.csv のいずれの形式でもありません")
+	}
+
+	err := c.update(func(tx catalogTx) error {
+		for _, row := range rows {
+			if err := importRow(tx, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// history.csv の1行を取り込む
+//
+// 番組情報は行ごとにダウンロード試行へ記録し、書き出すときはそれを使う。
+// エピソードのページでない行もエピソードなしのダウンロード試行として残す。
+func (tx catalogTx) importHistoryRecord(record HistoryRecord, source string, result *CatalogImportResult) error {
+	finishedAt := record.DownloadDate
+	download := CatalogDownload{
+		URL:           record.VideoPage,
+		SeriesURL:     record.VideoSeriesPage,
+		Genre:         record.Genre,
+		Status:        downloadSucceeded,
+		Validated:     record.VideoValidated,
+		VideoDir:      record.VideoDir,
+		VideoName:     record.VideoName,
+		VideoPath:     record.VideoPath,
+		StartedAt:     finishedAt,
+		FinishedAt:    finishedAt,
+		Source:        source,
+		Series:        record.Series,
+		Season:        record.Season,
+		Title:         record.Title,
+		Media:         record.Media,
+		BroadcastDate: record.BroadcastDate,
+	}
+	switch {
+	case historySkippedNames[download.VideoName]:
+		download.Status = downloadSkipped
+	case record.VideoValidated == validationFailed:
+		download.Status = downloadFailed
+		download.Error = "動画の検証に失敗"
+	}
+
+	if episodeID, err := extractEpisodeID(record.VideoPage); err == nil {
+		download.EpisodeID = episodeID
+		episode, err := tx.episode(episodeID)
+		if err != nil {
+			return err
+		}
+		if seriesID := seriesIDFromURL(record.VideoSeriesPage); seriesID != "" {
+			episode.SeriesID = seriesID
+		}
+		setIfEmpty(&episode.Title, record.Title)
+		setIfEmpty(&episode.SeriesTitle, record.Series)
+		setIfEmpty(&episode.SeasonTitle, record.Season)
+		setIfEmpty(&episode.Broadcaster, record.Media)
+		setIfEmpty(&episode.BroadcastDate, record.BroadcastDate)
+		setIfEmpty(&episode.Genre, record.Genre)
+		if episode.UpdatedAt.IsZero() {
+			episode.UpdatedAt = finishedAt
+		}
+		if err := tx.putEpisode(episode); err != nil {
+			return err
+		}
+		result.Episodes++
+	}
+
+	inserted, err := tx.insertDownload(download)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		result.Duplicate++
+		return nil
+	}
+	result.Downloads++
+	return nil
+}
+
+// list.csv の1行を取り込む (ダウンロード試行は含まない)
+func (tx catalogTx) importListRow(row map[string]string, result *CatalogImportResult) error {
+	episodeID := strings.TrimSpace(row["episodeID"])
+	if episodeID == "" {
+		result.Invalid++
+		return nil
+	}
+
+	episode, err := tx.episode(episodeID)
+	if err != nil {
+		return err
+	}
+	setIfNotEmpty(&episode.SeriesID, row["seriesID"])
+	setIfNotEmpty(&episode.SeasonID, row["seasonID"])
+	setIfNotEmpty(&episode.Title, row["episodeName"])
+	setIfNotEmpty(&episode.SeriesTitle, row["seriesName"])
+	setIfNotEmpty(&episode.SeasonTitle, row["seasonName"])
+	setIfNotEmpty(&episode.Broadcaster, row["media"])
+	setIfNotEmpty(&episode.BroadcastDate, row["broadcastDate"])
+	setIfNotEmpty(&episode.Description, row["descriptionText"])
+	setIfNotEmpty(&episode.Genre, row["keyword"])
+	if num, err := strconv.Atoi(row["episodeNo"]); err == nil {
+		episode.EpisodeNumber = num
+	}
+	if endAt, ok := parseListEndTime(row["endTime"]); ok {
+		episode.EndAt = endAt.Unix()
+	}
+	episode.UpdatedAt = time.Now()
+	if err := tx.putEpisode(episode); err != nil {
+		return err
+	}
+	result.Episodes++
+
+	if episode.SeriesID != "" {
+		err := tx.putSeries(CatalogSeries{
+			ID:          episode.SeriesID,
+			Title:       episode.SeriesTitle,
+			Broadcaster: episode.Broadcaster,
+			UpdatedAt:   episode.UpdatedAt,
+		}, false)
+		if err != nil {
+			return err
+		}
+	}
+	if episode.SeasonID != "" {
+		return tx.putSeason(CatalogSeason{ID: episode.SeasonID, SeriesID: episode.SeriesID, Title: episode.SeasonTitle}, false)
+	}
+	return nil
+}
+
+// list.csv の endTime を解析
+func parseListEndTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range listEndTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, jst); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// シリーズページのURLからシリーズIDを取り出す (取り出せなければ空)
+func seriesIDFromURL(url string) string {
+	_, id, ok := strings.Cut(url, "/series/")
+	if !ok {
+		return ""
+	}
+	id, _, _ = strings.Cut(id, "/")
+	id, _, _ = strings.Cut(id, "?")
+	return id
+}
+
+func setIfEmpty(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
+
+func setIfNotEmpty(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
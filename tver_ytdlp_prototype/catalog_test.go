@@ -0,0 +1,229 @@
+// catalog_test.go
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 一時ディレクトリに空のカタログを作成
+func newTestCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	catalog, err := OpenCatalog(filepath.Join(t.TempDir(), "catalog.db"))
+	if err != nil {
+		t.Fatalf("OpenCatalog: %v", err)
+	}
+	t.Cleanup(func() { catalog.Close() })
+	return catalog
+}
+
+func catalogUserVersion(t *testing.T, c *Catalog) int {
+	t.Helper()
+	var version int
+	if err := c.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatalf("user_version: %v", err)
+	}
+	return version
+}
+
+func TestOpenCatalogMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.db")
+	catalog, err := OpenCatalog(path)
+	if err != nil {
+		t.Fatalf("OpenCatalog: %v", err)
+	}
+	if got := catalogUserVersion(t, catalog); got != len(catalogMigrations) {
+		t.Errorf("user_version = %d, want %d", got, len(catalogMigrations))
+	}
+	if err := catalog.RecordDownload(CatalogDownload{EpisodeID: "ep1", Status: downloadSucceeded}); err != nil {
+		t.Fatalf("RecordDownload: %v", err)
+	}
+	catalog.Close()
+
+	// 移行済みのカタログを開き直しても記録は残る
+	catalog, err = OpenCatalog(path)
+	if err != nil {
+		t.Fatalf("OpenCatalog (2回目): %v", err)
+	}
+	if !catalog.IsDownloaded("ep1") {
+		t.Error("開き直したカタログで ep1 がダウンロード済みになっていません")
+	}
+
+	// 新しいバージョンで作成されたカタログは開かない
+	if _, err := catalog.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(catalogMigrations)+1)); err != nil {
+		t.Fatalf("user_version: %v", err)
+	}
+	catalog.Close()
+	if _, err := OpenCatalog(path); err == nil || !strings.Contains(err.Error(), "新しいバージョン") {
+		t.Errorf("新しいバージョンのカタログ: err = %v", err)
+	}
+}
+
+func TestCatalogIsDownloaded(t *testing.T) {
+	base := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name      string
+		downloads []CatalogDownload // 記録する順
+		want      bool
+	}{
+		{"記録なし", nil, false},
+		{"成功", []CatalogDownload{{Status: downloadSucceeded, Validated: validationOK}}, true},
+		{"未検証の成功", []CatalogDownload{{Status: downloadSucceeded, Validated: validationPending}}, true},
+		{"検証失敗", []CatalogDownload{{Status: downloadSucceeded, Validated: validationFailed}}, false},
+		{"対象外", []CatalogDownload{{Status: downloadSkipped}}, true},
+		{"失敗", []CatalogDownload{{Status: downloadFailed}}, false},
+		{"成功の後に失敗", []CatalogDownload{
+			{Status: downloadSucceeded, FinishedAt: base},
+			{Status: downloadFailed, FinishedAt: base.Add(time.Hour)},
+		}, false},
+		{"失敗の後に成功", []CatalogDownload{
+			{Status: downloadFailed, FinishedAt: base},
+			{Status: downloadSucceeded, FinishedAt: base.Add(time.Hour)},
+		}, true},
+		// 取り込んだ履歴は記録順ではなく終了日時で最新を決める
+		{"古い失敗を後から取り込み", []CatalogDownload{
+			{Status: downloadSucceeded, FinishedAt: base.Add(time.Hour)},
+			{Status: downloadFailed, FinishedAt: base, Source: "history.csv"},
+		}, true},
+	}
+	for _, tc := range cases {
+		catalog := newTestCatalog(t)
+		for _, download := range tc.downloads {
+			download.EpisodeID = "ep1"
+			if err := catalog.RecordDownload(download); err != nil {
+				t.Fatalf("%s: RecordDownload: %v", tc.name, err)
+			}
+		}
+		if got := catalog.IsDownloaded("ep1"); got != tc.want {
+			t.Errorf("%s: IsDownloaded = %v, want %v", tc.name, got, tc.want)
+		}
+		if catalog.IsDownloaded("ep2") {
+			t.Errorf("%s: 記録のない ep2 がダウンロード済みになっています", tc.name)
+		}
+	}
+}
+
+func TestCatalogStats(t *testing.T) {
+	catalog := newTestCatalog(t)
+	day1 := time.Date(2025, 4, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+
+	err := catalog.UpsertEpisodeDetail(&EpisodeDetail{ID: "ep1", SeriesID: "sr1", SeriesTitle: "番組A", Title: "第1話", Broadcaster: "局A"})
+	if err != nil {
+		t.Fatalf("UpsertEpisodeDetail: %v", err)
+	}
+	err = catalog.UpsertEpisodeDetail(&EpisodeDetail{ID: "ep2", SeriesID: "sr2", SeriesTitle: "番組B", Title: "第1話", Broadcaster: "局B"})
+	if err != nil {
+		t.Fatalf("UpsertEpisodeDetail: %v", err)
+	}
+	downloads := []CatalogDownload{
+		{EpisodeID: "ep1", Status: downloadSucceeded, Bytes: 100, StartedAt: day1.Add(-10 * time.Minute), FinishedAt: day1},
+		{EpisodeID: "ep2", Status: downloadFailed, StartedAt: day1, FinishedAt: day1},
+		{EpisodeID: "ep2", Status: downloadSucceeded, Bytes: 200, StartedAt: day2.Add(-20 * time.Minute), FinishedAt: day2},
+		{EpisodeID: "ep3", Status: downloadSkipped, StartedAt: day2, FinishedAt: day2},
+		// 取り込んだ履歴は所要時間の集計に含めない
+		{EpisodeID: "ep1", Status: downloadSucceeded, Bytes: 50, StartedAt: day2, FinishedAt: day2, Source: "history.csv"},
+	}
+	for _, download := range downloads {
+		if err := catalog.RecordDownload(download); err != nil {
+			t.Fatalf("RecordDownload: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name      string
+		since     time.Time
+		total     HistoryStatsGroup
+		days      int
+		timed     int
+		average   time.Duration
+		topSeries string
+	}{
+		{"全期間", time.Time{}, HistoryStatsGroup{Succeeded: 3, Failed: 1, Skipped: 1, Bytes: 350}, 2, 2, 15 * time.Minute, "番組A"},
+		{"2日目以降", day2.Add(-time.Hour), HistoryStatsGroup{Succeeded: 2, Skipped: 1, Bytes: 250}, 1, 1, 20 * time.Minute, "番組A"},
+		{"記録なし", day2.Add(time.Hour), HistoryStatsGroup{}, 0, 0, 0, ""},
+	}
+	for _, tc := range cases {
+		stats := catalog.Stats(tc.since)
+		tc.total.Name = stats.Total.Name
+		if stats.Total != tc.total {
+			t.Errorf("%s: Total = %+v, want %+v", tc.name, stats.Total, tc.total)
+		}
+		if len(stats.PerDay) != tc.days {
+			t.Errorf("%s: PerDay = %d日, want %d日", tc.name, len(stats.PerDay), tc.days)
+		}
+		if stats.TimedDownloads != tc.timed || stats.AverageDuration != tc.average {
+			t.Errorf("%s: 所要時間 = %d件 %v, want %d件 %v", tc.name, stats.TimedDownloads, stats.AverageDuration, tc.timed, tc.average)
+		}
+		var topSeries string
+		if len(stats.PerSeries) > 0 {
+			topSeries = stats.PerSeries[0].Name
+		}
+		if topSeries != tc.topSeries {
+			t.Errorf("%s: PerSeries[0] = %q, want %q", tc.name, topSeries, tc.topSeries)
+		}
+	}
+
+	// エピソード情報のないダウンロード試行は放送局不明として集計する
+	stats := catalog.Stats(time.Time{})
+	var unknown *HistoryStatsGroup
+	for _, g := range stats.PerBroadcaster {
+		if g.Name == "(不明)" {
+			unknown = g
+		}
+	}
+	if unknown == nil || unknown.Skipped != 1 {
+		t.Errorf("放送局不明の集計 = %+v", unknown)
+	}
+}
+
+func TestCatalogConcurrentWriters(t *testing.T) {
+	// serve と CLI のように別々に開いたカタログから同時に書き込んでも記録は失われない
+	path := filepath.Join(t.TempDir(), "catalog.db")
+	var catalogs []*Catalog
+	for range 2 {
+		catalog, err := OpenCatalog(path)
+		if err != nil {
+			t.Fatalf("OpenCatalog: %v", err)
+		}
+		defer catalog.Close()
+		catalogs = append(catalogs, catalog)
+	}
+
+	const perWriter = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, len(catalogs)*perWriter)
+	for i, catalog := range catalogs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range perWriter {
+				id := fmt.Sprintf("ep%d_%d", i, j)
+				if err := catalog.UpsertEpisodeDetail(&EpisodeDetail{ID: id, Title: id}); err != nil {
+					errs <- err
+				}
+				if err := catalog.RecordDownload(CatalogDownload{EpisodeID: id, Status: downloadSucceeded}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("同時書き込みエラー: %v", err)
+	}
+
+	for i := range catalogs {
+		for j := range perWriter {
+			id := fmt.Sprintf("ep%d_%d", i, j)
+			if !catalogs[0].IsDownloaded(id) {
+				t.Errorf("%s の記録が失われました", id)
+			}
+		}
+	}
+}
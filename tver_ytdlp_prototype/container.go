@@ -0,0 +1,91 @@
+// container.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// コンテナ形式ごとの対応機能
+type containerCapability struct {
+	Subtitles bool // 字幕トラックの埋め込み
+	Metadata  bool // タイトル・番組名などのメタデータ
+	CoverArt  bool // カバーアート
+}
+
+// 選択可能なコンテナ形式
+var containerFormats = map[string]containerCapability{
+	"mp4": {Subtitles: true, Metadata: true, CoverArt: true},
+	"mkv": {Subtitles: true, Metadata: true, CoverArt: true},
+	"ts":  {Subtitles: false, Metadata: false, CoverArt: false},
+}
+
+// コンテナ形式と埋め込みオプションの組み合わせを検証
+func (d *TVerDownloader) ValidateContainer() error {
+	capability, ok := containerFormats[d.ContainerFormat]
+	if !ok {
+		return fmt.Errorf("不明なコンテナ形式: %s (mp4, mkv, ts のいずれかを指定してください)", d.ContainerFormat)
+	}
+
+	if d.EmbedSubtitle && !capability.Subtitles {
+		return fmt.Errorf("%sは字幕の埋め込みに対応していません", d.ContainerFormat)
+	}
+	if d.EmbedMetatag && !capability.Metadata {
+		return fmt.Errorf("%sはメタデータの埋め込みに対応していません", d.ContainerFormat)
+	}
+	if d.EmbedThumbnail && !capability.CoverArt {
+		return fmt.Errorf("%sはカバーアートの埋め込みに対応していません", d.ContainerFormat)
+	}
+	return nil
+}
+
+// コンテナ形式に応じたyt-dlp引数を構築
+func (d *TVerDownloader) containerArgs() []string {
+	switch d.ContainerFormat {
+	case "mp4", "mkv":
+		return []string{
+			"--merge-output-format", d.ContainerFormat,
+			"--remux-video", d.ContainerFormat,
+		}
+	}
+	// tsはyt-dlpが直接出力できないため、ダウンロード後にffmpegで変換する
+	return nil
+}
+
+// 指定のコンテナ形式でなければffmpegで再エンコードせずに変換し、新しいパスを返す
+func (d *TVerDownloader) remuxIfNeeded(mediaPath string) (string, error) {
+	ext := filepath.Ext(mediaPath)
+	if d.ContainerFormat == "" || strings.EqualFold(ext, "."+d.ContainerFormat) {
+		return mediaPath, nil
+	}
+
+	outPath := strings.TrimSuffix(mediaPath, ext) + "." + d.ContainerFormat
+	fmt.Printf("コンテナを変換: %s -> %s\n", ext, d.ContainerFormat)
+	if output, err := exec.Command(d.FfmpegPath, remuxArgs(mediaPath, outPath, d.ContainerFormat)...).CombinedOutput(); err != nil {
+		os.Remove(outPath)
+		return "", fmt.Errorf("ffmpegコンテナ変換エラー: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	if err := os.Remove(mediaPath); err != nil {
+		return "", fmt.Errorf("変換前ファイル削除エラー: %w", err)
+	}
+	return outPath, nil
+}
+
+// コンテナ変換のffmpeg引数を構築
+func remuxArgs(mediaPath, outPath, format string) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", mediaPath}
+	switch format {
+	case "mp4":
+		args = append(args, "-map", "0", "-c", "copy", "-c:s", "mov_text")
+	case "mkv":
+		args = append(args, "-map", "0", "-c", "copy")
+	case "ts":
+		// 字幕などtsに入れられないトラックを除く (音声のない動画もあるため音声は任意)
+		args = append(args, "-map", "0:v", "-map", "0:a?", "-c", "copy", "-f", "mpegts")
+	}
+	return append(args, outPath)
+}
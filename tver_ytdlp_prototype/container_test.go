@@ -0,0 +1,65 @@
+// container_test.go
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValidateContainer(t *testing.T) {
+	cases := []struct {
+		name                   string
+		format                 string
+		subs, metatag, artwork bool
+		ok                     bool
+	}{
+		{"mp4で全て埋め込み", "mp4", true, true, true, true},
+		{"mkvで全て埋め込み", "mkv", true, true, true, true},
+		{"tsで埋め込みなし", "ts", false, false, false, true},
+		{"tsに字幕", "ts", true, false, false, false},
+		{"tsにメタデータ", "ts", false, true, false, false},
+		{"tsにカバーアート", "ts", false, false, true, false},
+		{"不明な形式", "avi", false, false, false, false},
+	}
+	for _, c := range cases {
+		d := NewTVerDownloader(".")
+		d.ContainerFormat = c.format
+		d.EmbedSubtitle, d.EmbedMetatag, d.EmbedThumbnail = c.subs, c.metatag, c.artwork
+		if err := d.ValidateContainer(); (err == nil) != c.ok {
+			t.Errorf("%s: ValidateContainer() = %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+}
+
+func TestContainerArgs(t *testing.T) {
+	d := NewTVerDownloader(".")
+	d.ContainerFormat = "mkv"
+	if got, want := d.containerArgs(), []string{"--merge-output-format", "mkv", "--remux-video", "mkv"}; !slices.Equal(got, want) {
+		t.Errorf("mkv: containerArgs() = %v, want %v", got, want)
+	}
+	d.ContainerFormat = "ts"
+	if got := d.containerArgs(); got != nil {
+		t.Errorf("ts: containerArgs() = %v, want なし", got)
+	}
+}
+
+func TestRemuxArgs(t *testing.T) {
+	got := remuxArgs("a.mp4", "a.ts", "ts")
+	want := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", "a.mp4",
+		"-map", "0:v", "-map", "0:a?", "-c", "copy", "-f", "mpegts", "a.ts"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ts: remuxArgs() = %v, want %v", got, want)
+	}
+	if got := remuxArgs("a.mkv", "a.mp4", "mp4"); !slices.Contains(got, "mov_text") {
+		t.Errorf("mp4: 字幕をmov_textに変換していません: %v", got)
+	}
+}
+
+func TestRemuxIfNeededSameFormat(t *testing.T) {
+	d := NewTVerDownloader(".")
+	d.FfmpegPath = "存在しないffmpeg"
+	got, err := d.remuxIfNeeded("out/a.MP4")
+	if err != nil || got != "out/a.MP4" {
+		t.Errorf("remuxIfNeeded = %q, %v; want 変換なし", got, err)
+	}
+}
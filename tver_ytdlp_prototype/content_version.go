@@ -0,0 +1,61 @@
+// content_version.go
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 一括ダウンロードでダウンロード済みのエピソードの新しいバージョンを再確認する間隔
+const versionRecheckInterval = 24 * time.Hour
+
+// 最後に成功したダウンロードの番組バージョン (記録がなければ0)
+func (c *Catalog) DownloadedVersion(episodeID string) int {
+	var version int
+	err := c.db.QueryRow(`SELECT version FROM downloads WHERE episode_id = ? AND status = ? ORDER BY id DESC LIMIT 1`,
+		episodeID, downloadSucceeded).Scan(&version)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logCatalogError(err)
+	}
+	return version
+}
+
+// ダウンロード済みのエピソードに新しいバージョンが公開されているか確認
+// (PowerShell版から取り込んだ履歴などバージョンの記録がないものは対象外)
+func (d *TVerDownloader) hasNewVersion(detail *EpisodeDetail) bool {
+	downloaded := d.Catalog.DownloadedVersion(detail.ID)
+	if downloaded == 0 || detail.Version <= downloaded {
+		return false
+	}
+	fmt.Printf("新しいバージョンが公開されています: %s (%d → %d)\n", detail.ID, downloaded, detail.Version)
+	return true
+}
+
+// 一括ダウンロードで新しいバージョンを確認すべきか
+// (バージョンを記録していて、配信終了前で、最近確認していないもの。endAtは探索時に分かった配信終了日時で0なら不明)
+func (c *Catalog) VersionCheckDue(episodeID string, endAt int64, now time.Time) bool {
+	if c.DownloadedVersion(episodeID) == 0 {
+		return false
+	}
+	episode, ok := c.Episode(episodeID)
+	if endAt == 0 {
+		endAt = episode.EndAt
+	}
+	if endAt != 0 && !now.Before(time.Unix(endAt, 0)) {
+		return false
+	}
+	return !ok || episode.VersionCheckedAt.IsZero() || now.Sub(episode.VersionCheckedAt) >= versionRecheckInterval
+}
+
+// 新しいバージョンを確認した日時を記録
+func (c *Catalog) MarkVersionChecked(episodeID string, at time.Time) error {
+	_, err := c.db.Exec(`INSERT INTO episodes (id, version_checked_at) VALUES (?, ?)
+		ON CONFLICT (id) DO UPDATE SET version_checked_at = excluded.version_checked_at`,
+		episodeID, catalogTime(at))
+	if err != nil {
+		return fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// content_version_test.go
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHasNewVersion(t *testing.T) {
+	cases := []struct {
+		name      string
+		downloads []CatalogDownload // 記録する順
+		version   int               // 公開されているバージョン
+		want      bool
+	}{
+		{"記録なし", nil, 5, false},
+		{"バージョン不明 (取り込んだ履歴)", []CatalogDownload{{Status: downloadSucceeded, Source: "history.csv"}}, 5, false},
+		{"同じバージョン", []CatalogDownload{{Status: downloadSucceeded, Version: 5}}, 5, false},
+		{"古いバージョンが公開", []CatalogDownload{{Status: downloadSucceeded, Version: 5}}, 4, false},
+		{"新しいバージョン", []CatalogDownload{{Status: downloadSucceeded, Version: 4}}, 5, true},
+		{"最後の成功のバージョンで比べる", []CatalogDownload{
+			{Status: downloadSucceeded, Version: 4},
+			{Status: downloadSucceeded, Version: 5},
+			{Status: downloadFailed, Version: 6},
+		}, 5, false},
+		{"失敗のみ", []CatalogDownload{{Status: downloadFailed, Version: 4}}, 5, false},
+	}
+	for _, tc := range cases {
+		d := NewTVerDownloader(t.TempDir())
+		d.Catalog = newTestCatalog(t)
+		for _, download := range tc.downloads {
+			download.EpisodeID = "ep1"
+			if err := d.Catalog.RecordDownload(download); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := d.hasNewVersion(&EpisodeDetail{ID: "ep1", Version: tc.version}); got != tc.want {
+			t.Errorf("%s: hasNewVersion = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestVersionCheckDue(t *testing.T) {
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.Local)
+	future := now.Add(48 * time.Hour).Unix()
+	past := now.Add(-time.Hour).Unix()
+	cases := []struct {
+		name      string
+		version   int // ダウンロードしたバージョン (0なら記録なし)
+		episode   *CatalogEpisode
+		endAt     int64     // 探索時に分かった配信終了日時
+		checkedAt time.Time // 最後に確認した日時
+		want      bool
+	}{
+		{"バージョン記録なし", 0, nil, 0, time.Time{}, false},
+		{"未確認", 5, nil, 0, time.Time{}, true},
+		{"配信終了前", 5, &CatalogEpisode{EndAt: future}, 0, time.Time{}, true},
+		{"配信終了", 5, &CatalogEpisode{EndAt: past}, 0, time.Time{}, false},
+		{"探索時の配信終了日時を優先", 5, &CatalogEpisode{EndAt: future}, past, time.Time{}, false},
+		{"最近確認した", 5, nil, future, now.Add(-time.Hour), false},
+		{"確認から間隔が経った", 5, nil, future, now.Add(-versionRecheckInterval), true},
+	}
+	for _, tc := range cases {
+		catalog := newTestCatalog(t)
+		if tc.episode != nil {
+			tc.episode.ID = "ep1"
+			if err := catalog.update(func(tx catalogTx) error { return tx.putEpisode(tc.episode) }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tc.version > 0 {
+			if err := catalog.RecordDownload(CatalogDownload{EpisodeID: "ep1", Status: downloadSucceeded, Version: tc.version}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !tc.checkedAt.IsZero() {
+			if err := catalog.MarkVersionChecked("ep1", tc.checkedAt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := catalog.VersionCheckDue("ep1", tc.endAt, now); got != tc.want {
+			t.Errorf("%s: VersionCheckDue = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMarkVersionCheckedKeepsEpisode(t *testing.T) {
+	catalog := newTestCatalog(t)
+	if err := catalog.UpsertEpisodeDetail(&EpisodeDetail{ID: "ep1", Title: "第1話", EndAt: 1742400000}); err != nil {
+		t.Fatal(err)
+	}
+	checkedAt := time.Date(2025, 4, 1, 12, 0, 0, 0, time.Local)
+	if err := catalog.MarkVersionChecked("ep1", checkedAt); err != nil {
+		t.Fatal(err)
+	}
+	// エピソード情報を更新しても確認日時は残る
+	if err := catalog.UpsertEpisodeDetail(&EpisodeDetail{ID: "ep1", Title: "第1話 (字幕付き)", EndAt: 1742400000}); err != nil {
+		t.Fatal(err)
+	}
+	episode, ok := catalog.Episode("ep1")
+	if !ok || episode.Title != "第1話 (字幕付き)" || episode.EndAt != 1742400000 || !episode.VersionCheckedAt.Equal(checkedAt) {
+		t.Errorf("episode = %+v", episode)
+	}
+}
+
+func TestDownloadEpisodeWithDetailUsesGivenDetail(t *testing.T) {
+	d := NewTVerDownloader(t.TempDir())
+	d.Catalog = newTestCatalog(t)
+	d.RedownloadNewVersion = true
+	d.Client = newTestTVerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("番組情報を再取得しました: %s", r.URL)
+		http.Error(w, "unexpected", http.StatusInternalServerError)
+	})
+	if err := d.Catalog.RecordDownload(CatalogDownload{EpisodeID: "ep1", Status: downloadSucceeded, Version: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	_, err := d.DownloadEpisodeWithDetail("https://tver.jp/episodes/ep1", &EpisodeDetail{ID: "ep1", Version: 5})
+	if !errors.Is(err, ErrAlreadyDownloaded) {
+		t.Fatalf("err = %v, want ErrAlreadyDownloaded", err)
+	}
+	// 確認した日時を記録し、次の一括ダウンロードでは確認しない
+	if d.Catalog.VersionCheckDue("ep1", 0, time.Now()) {
+		t.Error("確認直後に再確認の対象になっています")
+	}
+	if episode, _ := d.Catalog.Episode("ep1"); episode.VersionCheckedAt.Before(before) {
+		t.Errorf("VersionCheckedAt = %v", episode.VersionCheckedAt)
+	}
+}
@@ -0,0 +1,245 @@
+// discovery.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 探索元 (マイページはユーザーの認証情報が必要)
+var discoverSources = map[string]bool{
+	"sitemap":         true,
+	"top":             true,
+	"mypage/fav":      true,
+	"mypage/later":    true,
+	"mypage/resume":   true,
+	"mypage/favorite": true,
+}
+
+// discover の動作設定
+type DiscoverOptions struct {
+	Sources     []string // sitemap, top, mypage/fav, mypage/later, mypage/resume, mypage/favorite
+	EpisodeOnly bool     // サイトマップのエピソードページのみを対象にする
+	StatePath   string   // ダウンロード済みエピソードの記録先
+	DryRun      bool     // 新着の表示のみ
+}
+
+// discover でダウンロード済みのエピソード
+type DiscoverState struct {
+	Downloaded map[string]time.Time `json:"downloaded"`
+}
+
+// 記録ファイルを読み込み (存在しなければ空の状態を返す)
+func LoadDiscoverState(path string) (*DiscoverState, error) {
+	state := &DiscoverState{Downloaded: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("記録ファイル読み込みエラー: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("記録ファイル解析エラー: %w", err)
+	}
+	if state.Downloaded == nil {
+		state.Downloaded = make(map[string]time.Time)
+	}
+	return state, nil
+}
+
+// 記録ファイルを保存 (一時ファイル経由で置き換え)
+func (s *DiscoverState) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("JSON生成エラー: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("記録ファイル書き込みエラー: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("記録ファイル書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// カテゴリ・特集・タレント・シリーズ・シーズンをエピソードに展開
+func expandLinks(client *TVerClient, lc *LinkCollection) {
+	sm := NewSeriesManager()
+	sm.Client = client
+
+	// カテゴリや特集の中で見つかった特集・タレントも続けて展開する
+	var categories, specials, details, talents int
+	for categories < len(lc.Categories) || specials < len(lc.Specials) || details < len(lc.SpecialDetails) || talents < len(lc.Talents) {
+		for ; categories < len(lc.Categories); categories++ {
+			if err := client.CollectFromCategory(lc.Categories[categories], lc); err != nil {
+				log.Printf("カテゴリ %s の展開エラー: %v", lc.Categories[categories], err)
+			}
+		}
+		for ; specials < len(lc.Specials); specials++ {
+			if err := client.CollectFromSpecial(lc.Specials[specials], lc); err != nil {
+				log.Printf("特集 %s の展開エラー: %v", lc.Specials[specials], err)
+			}
+		}
+		for ; details < len(lc.SpecialDetails); details++ {
+			if err := client.CollectFromSpecialDetail(lc.SpecialDetails[details], lc); err != nil {
+				log.Printf("特集 %s の展開エラー: %v", lc.SpecialDetails[details], err)
+			}
+		}
+		for ; talents < len(lc.Talents); talents++ {
+			if err := client.CollectFromTalent(lc.Talents[talents], lc); err != nil {
+				log.Printf("タレント %s の展開エラー: %v", lc.Talents[talents], err)
+			}
+		}
+	}
+
+	for _, seriesID := range lc.Series {
+		seriesInfo, err := sm.GetSeriesInfo(fmt.Sprintf("https://tver.jp/series/%s", seriesID))
+		if err != nil {
+			log.Printf("シリーズ %s の展開エラー: %v", seriesID, err)
+			continue
+		}
+		for _, entry := range seriesInfo.Entries {
+			lc.AddEpisode(entry.ID, entry.EndAt)
+		}
+	}
+
+	for _, seasonID := range lc.Seasons {
+		episodes, err := client.GetSeasonEpisodes(seasonID)
+		if err != nil {
+			log.Printf("シーズン %s の展開エラー: %v", seasonID, err)
+			continue
+		}
+		for _, entry := range episodes {
+			lc.AddEpisode(entry.ID, entry.EndAt)
+		}
+	}
+}
+
+// サイトマップ・トップページから番組を探索し、未ダウンロードのエピソードをまとめてダウンロード
+func RunDiscover(downloader *TVerDownloader, opts DiscoverOptions) error {
+	client, err := downloader.tverClient()
+	if err != nil {
+		return err
+	}
+
+	lc := NewLinkCollection()
+	for _, source := range opts.Sources {
+		fmt.Printf("探索中: %s\n", source)
+		switch source {
+		case "sitemap":
+			err = client.CollectFromSiteMap(lc, opts.EpisodeOnly)
+		case "top":
+			err = client.CollectFromTopPage(lc)
+		default:
+			if page, ok := strings.CutPrefix(source, "mypage/"); ok {
+				err = client.CollectFromMyPage(page, lc)
+			}
+		}
+		if err != nil {
+			log.Printf("%s の探索エラー: %v", source, err)
+		}
+	}
+	fmt.Printf("エピソード %d件, シリーズ %d件, シーズン %d件を発見\n", len(lc.Episodes), len(lc.Series), len(lc.Seasons))
+
+	expandLinks(client, lc)
+
+	state, err := LoadDiscoverState(opts.StatePath)
+	if err != nil {
+		return err
+	}
+
+	// 未ダウンロードのものを配信終了が近い順に並べる (配信終了日時が不明なものは最後)
+	// 他のコマンドでダウンロードしたものはカタログで判定する
+	var pending, recheck []string
+	now := time.Now()
+	for _, id := range lc.EpisodeIDs() {
+		_, downloaded := state.Downloaded[id]
+		if downloader.Catalog != nil && downloader.Catalog.IsDownloaded(id) {
+			downloaded = true
+		}
+		switch {
+		case !downloaded:
+			pending = append(pending, id)
+		case downloader.RedownloadNewVersion && downloader.Catalog != nil && downloader.Catalog.VersionCheckDue(id, lc.Episodes[id], now):
+			// バージョンを記録しているものは配信終了前なら新しいバージョンが公開されていないか確認する
+			// (API呼び出しを抑えるため、確認してから versionRecheckInterval 経つまでは再確認しない)
+			recheck = append(recheck, id)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		a, b := lc.Episodes[pending[i]], lc.Episodes[pending[j]]
+		if (a == 0) != (b == 0) {
+			return a != 0
+		}
+		return a < b
+	})
+
+	fmt.Printf("\n新着エピソード: %d件 (全%d件中)\n", len(pending), len(lc.Episodes))
+	if len(recheck) > 0 {
+		fmt.Printf("新しいバージョンを確認するダウンロード済みエピソード: %d件\n", len(recheck))
+	}
+	if opts.DryRun {
+		for _, id := range pending {
+			fmt.Printf("  https://tver.jp/episodes/%s\n", id)
+		}
+		return nil
+	}
+
+	var completed, failed int
+	targets := append(pending, recheck...)
+	for i, id := range targets {
+		url := fmt.Sprintf("https://tver.jp/episodes/%s", id)
+		fmt.Printf("\n[%d/%d] %s\n", i+1, len(targets), url)
+
+		// 番組ごとのディレクトリに保存
+		detail, err := client.GetEpisodeDetail(id)
+		if detail == nil {
+			log.Printf("番組情報取得エラー: %v", err)
+			failed++
+			downloader.notify(notifyDownloadFailed, "番組情報取得失敗: "+id, err.Error(), url)
+			continue
+		}
+		seriesDownloader, err := downloader.ForSeries(detail.SeriesTitle)
+		if err != nil {
+			return err
+		}
+
+		// 取得した番組情報を渡し、ダウンロード時に再取得しない
+		switch _, err := seriesDownloader.DownloadEpisodeWithDetail(url, detail); {
+		case errors.Is(err, ErrAlreadyDownloaded):
+			fmt.Println("ダウンロード済みのためスキップ")
+		case errors.Is(err, ErrInsufficientDiskSpace):
+			downloader.notify(notifyDownloadFailed, "ダウンロード中断: "+detail.SeriesTitle, err.Error(), url)
+			return err
+		case err != nil:
+			log.Printf("エピソード %s のダウンロードエラー: %v", id, err)
+			failed++
+			downloader.notify(notifyDownloadFailed, "ダウンロード失敗: "+detail.SeriesTitle+" "+detail.Title, err.Error(), url)
+			continue
+		default:
+			completed++
+		}
+
+		state.Downloaded[id] = time.Now()
+		if err := state.Save(opts.StatePath); err != nil {
+			return err
+		}
+	}
+
+	if completed+failed > 0 {
+		downloader.notify(notifySeriesCompleted, "一括ダウンロード完了: "+strings.Join(opts.Sources, ", "),
+			fmt.Sprintf("完了 %d話 / 失敗 %d話", completed, failed), "")
+	}
+	return nil
+}
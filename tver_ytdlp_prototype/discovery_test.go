@@ -0,0 +1,117 @@
+// discovery_test.go
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExpandLinks(t *testing.T) {
+	responses := map[string]string{
+		"/service/api/v1/callCategoryHome/drama": `{"Result": {"Components": [
+			{"Type": "banner", "Contents": [{"Type": "episode", "Content": {"Id": "banner-ep"}}]},
+			{"Type": "horizontal", "Contents": [{"Type": "specialMain", "Content": {"Id": "main1"}}]}]}}`,
+		"/service/api/v1/callSpecialContents/main1": `{"Result": {"specialContents": [
+			{"Type": "special", "Content": {"Id": "detail1"}}]}}`,
+		"/service/api/v1/callSpecialContentsDetail/detail1": `{"Result": {"Contents": {"Content": {"Contents": [
+			{"Type": "episode", "Content": {"Id": "ep1", "EndAt": 100}},
+			{"Type": "season", "Content": {"Id": "ss1"}},
+			{"Type": "talent", "Content": {"Id": "t1"}},
+			{"Type": "special", "Content": {"Id": "detail1"}}]}}}}`,
+		"/service/api/v1/callTalentEpisode/t1": `{"Result": {"Contents": [
+			{"Type": "episode", "Content": {"Id": "ep3"}},
+			{"Type": "episode", "Content": {"Id": "ep1"}}]}}`,
+		"/service/api/v1/callSeasonEpisodes/ss1": `{"Result": {"Contents": [
+			{"Type": "episode", "Content": {"Id": "ep2", "Title": "第2話", "EndAt": 200}}]}}`,
+	}
+	client := newTestTVerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	})
+
+	lc := NewLinkCollection()
+	lc.AddLink("categories", "drama", false)
+	lc.AddLink("categories", "missing", false) // 取得できないものは飛ばして続ける
+	expandLinks(client, lc)
+
+	if got := strings.Join(lc.EpisodeIDs(), ","); got != "ep1,ep3,ep2" {
+		t.Errorf("EpisodeIDs = %s, want ep1,ep3,ep2", got)
+	}
+	if lc.Episodes["ep2"] != 200 {
+		t.Errorf("ep2 の配信終了日時 = %d", lc.Episodes["ep2"])
+	}
+}
+
+func TestExpandSiteMapSpecials(t *testing.T) {
+	// サイトマップの specials/<id> は特集の詳細ページとして展開する
+	var requested []string
+	client := newTestTVerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://tver.jp/episodes/ep1</loc></url>
+  <url><loc>https://tver.jp/specials/sp1</loc></url>
+</urlset>`))
+		case "/service/api/v1/callSpecialContentsDetail/sp1":
+			w.Write([]byte(`{"Result": {"Contents": {"Content": {"Contents": [
+				{"Type": "episode", "Content": {"Id": "ep2", "EndAt": 100}},
+				{"Type": "episode", "Content": {"Id": "ep1"}},
+				{"Type": "episode", "Content": {"Id": "ep3"}}]}}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	lc := NewLinkCollection()
+	if err := client.CollectFromSiteMap(lc, false); err != nil {
+		t.Fatalf("CollectFromSiteMap: %v", err)
+	}
+	expandLinks(client, lc)
+
+	if got := strings.Join(lc.EpisodeIDs(), ","); got != "ep1,ep2,ep3" {
+		t.Errorf("EpisodeIDs = %s, want ep1,ep2,ep3", got)
+	}
+	if lc.Episodes["ep2"] != 100 {
+		t.Errorf("ep2 の配信終了日時 = %d", lc.Episodes["ep2"])
+	}
+	for _, path := range requested {
+		if strings.HasPrefix(path, "/service/api/v1/callSpecialContents/") {
+			t.Errorf("特集の詳細ページを特集のメインページとして取得しました: %s", path)
+		}
+	}
+}
+
+func TestUserTVerClient(t *testing.T) {
+	// ブラウザのセッションがあれば匿名のトークンを取得せずにそれを使う
+	d := NewTVerDownloader(t.TempDir())
+	d.ForwardedIP = "203.0.113.1"
+	client, err := d.userTVerClient("uid1", "token1", "")
+	if err != nil {
+		t.Fatalf("userTVerClient: %v", err)
+	}
+	if client.PlatformUID != "uid1" || client.PlatformToken != "token1" || !client.HasUserCredentials() {
+		t.Errorf("client = %+v", client)
+	}
+	if client.ForwardedIP != "203.0.113.1" || d.Client != client {
+		t.Error("ダウンローダーの設定が反映されていません")
+	}
+
+	// 既に作成済みのクライアントには認証情報だけを設定する
+	d = NewTVerDownloader(t.TempDir())
+	d.Client = NewTVerClient()
+	d.Client.PlatformUID = "anonymous"
+	client, err = d.userTVerClient("", "", "sid1")
+	if err != nil {
+		t.Fatalf("userTVerClient: %v", err)
+	}
+	if client.PlatformUID != "anonymous" || client.MemberSID != "sid1" {
+		t.Errorf("client = %+v", client)
+	}
+}
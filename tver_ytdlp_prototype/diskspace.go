@@ -0,0 +1,69 @@
+// diskspace.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// 空き容量不足の再確認間隔
+const diskSpaceRetryInterval = time.Minute
+
+// 空き容量不足時のエラー
+var ErrInsufficientDiskSpace = errors.New("ディスクの空き容量が不足しています")
+
+// 出力ディレクトリ内の既定の作業ディレクトリ名 (メディアサーバーが読み込まないよう隠しディレクトリにする)
+const defaultWorkDirName = ".tver_ytdlp_work"
+
+// ダウンロード中の一時ファイルを書き込むディレクトリ
+// 既定では出力ディレクトリ内に置き、完了したファイルを同じファイルシステム内で移動できるようにする
+func (d *TVerDownloader) workDir() string {
+	if d.WorkDir != "" {
+		return d.WorkDir
+	}
+	return filepath.Join(d.OutputDir, defaultWorkDirName)
+}
+
+// ディレクトリの空き容量が下限(MB)以上あるか確認
+func checkFreeSpace(dir string, minMB uint64) error {
+	if minMB == 0 {
+		return nil
+	}
+
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		return fmt.Errorf("空き容量確認エラー: %w", err)
+	}
+
+	if freeMB := free / 1024 / 1024; freeMB < minMB {
+		return fmt.Errorf("%w: %s (空き %dMB / 下限 %dMB)", ErrInsufficientDiskSpace, dir, freeMB, minMB)
+	}
+	return nil
+}
+
+// 作業ディレクトリと出力ディレクトリの空き容量を確認
+func (d *TVerDownloader) checkDiskSpace() error {
+	if err := checkFreeSpace(d.workDir(), d.MinWorkDirCapacity); err != nil {
+		return err
+	}
+	return checkFreeSpace(d.OutputDir, d.MinOutputDirCapacity)
+}
+
+// 空き容量が回復するまで待機し、DiskSpaceWaitを過ぎても不足していればエラーを返す
+func (d *TVerDownloader) waitForDiskSpace() error {
+	deadline := time.Now().Add(d.DiskSpaceWait)
+	for {
+		err := d.checkDiskSpace()
+		if err == nil || !errors.Is(err, ErrInsufficientDiskSpace) || time.Now().After(deadline) {
+			return err
+		}
+		fmt.Printf("%v\n空き容量が回復するまで待機します...\n", err)
+		wait := diskSpaceRetryInterval
+		if remaining := time.Until(deadline) + time.Second; remaining < wait {
+			wait = remaining
+		}
+		time.Sleep(wait)
+	}
+}
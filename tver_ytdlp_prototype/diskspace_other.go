@@ -0,0 +1,12 @@
+// diskspace_other.go
+
+//go:build !linux && !darwin && !freebsd && !windows
+
+package main
+
+import "errors"
+
+// 空き容量を取得できない環境ではエラーにする (--min-free-work 0 --min-free-output 0 で確認を省略できる)
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errors.New("この環境では空き容量を確認できません (--min-free-work 0 --min-free-output 0 で確認を省略できます)")
+}
@@ -0,0 +1,39 @@
+// diskspace_test.go
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestFreeDiskSpace(t *testing.T) {
+	free, err := freeDiskSpace(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free == 0 {
+		t.Error("空き容量 = 0")
+	}
+
+	if _, err := freeDiskSpace(filepath.Join(t.TempDir(), "存在しないディレクトリ")); err == nil {
+		t.Error("存在しないディレクトリでエラーになりませんでした")
+	}
+}
+
+func TestCheckFreeSpaceInsufficient(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkFreeSpace(dir, 1); err != nil {
+		t.Errorf("下限1MB: %v", err)
+	}
+	// 1EB(エクサバイト)の空きがあるファイルシステムはない
+	if err := checkFreeSpace(dir, 1<<40); !errors.Is(err, ErrInsufficientDiskSpace) {
+		t.Errorf("下限1EB: err = %v, want ErrInsufficientDiskSpace", err)
+	}
+}
+
+func TestCheckFreeSpaceDisabled(t *testing.T) {
+	if err := checkFreeSpace("/存在しないディレクトリ", 0); err != nil {
+		t.Errorf("下限0で確認されました: %v", err)
+	}
+}
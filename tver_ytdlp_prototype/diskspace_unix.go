@@ -0,0 +1,23 @@
+// diskspace_unix.go
+
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// ディレクトリが属するファイルシステムの空き容量(バイト)を取得 (一般ユーザーが使える分のみ)
+func freeDiskSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("statfsエラー: %w", err)
+	}
+	// FreeBSDでは予約領域を使い込むと負になる
+	if stat.Bavail <= 0 {
+		return 0, nil
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
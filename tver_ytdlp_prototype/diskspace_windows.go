@@ -0,0 +1,22 @@
+// diskspace_windows.go
+
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// ディレクトリが属するドライブの空き容量(バイト)を取得 (クォータを考慮した呼び出し元が使える分のみ)
+func freeDiskSpace(dir string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, fmt.Errorf("パス変換エラー: %w", err)
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, &total, &free); err != nil {
+		return 0, fmt.Errorf("GetDiskFreeSpaceExエラー: %w", err)
+	}
+	return available, nil
+}
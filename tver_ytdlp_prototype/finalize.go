@@ -0,0 +1,205 @@
+// finalize.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ダウンロード結果の検証エラー
+var ErrDownloadInvalid = errors.New("ダウンロードした動画が不正です")
+
+// 移動先に同名のファイルがあり、置き換えが意図されていない
+var ErrDestinationExists = errors.New("保存先に同名のファイルがあります")
+
+// 動画ファイルとして扱う拡張子
+var mediaExtensions = map[string]bool{
+	".mp4":  true,
+	".mkv":  true,
+	".ts":   true,
+	".m4v":  true,
+	".webm": true,
+}
+
+// 動画ファイルかどうかを判定
+func isMediaFile(name string) bool {
+	return mediaExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// ダウンロード1件分の作業ディレクトリを作成
+func (d *TVerDownloader) createJobDir() (string, error) {
+	if err := os.MkdirAll(d.workDir(), 0755); err != nil {
+		return "", fmt.Errorf("作業ディレクトリ作成エラー: %w", err)
+	}
+	jobDir, err := os.MkdirTemp(d.workDir(), "job-")
+	if err != nil {
+		return "", fmt.Errorf("作業ディレクトリ作成エラー: %w", err)
+	}
+	return jobDir, nil
+}
+
+// 作業ディレクトリ内のダウンロード結果を検証し、動画ファイルのパスを返す
+func validateDownload(jobDir string) (string, error) {
+	entries, err := os.ReadDir(jobDir)
+	if err != nil {
+		return "", fmt.Errorf("作業ディレクトリ読み込みエラー: %w", err)
+	}
+
+	var mediaPath string
+	for _, entry := range entries {
+		name := entry.Name()
+		if isPartialFile(name) {
+			return "", fmt.Errorf("%w: 一時ファイルが残っています: %s", ErrDownloadInvalid, name)
+		}
+		if !isMediaFile(name) {
+			continue
+		}
+		if mediaPath != "" {
+			return "", fmt.Errorf("%w: 動画ファイルが複数あります", ErrDownloadInvalid)
+		}
+		mediaPath = filepath.Join(jobDir, name)
+	}
+
+	if mediaPath == "" {
+		return "", fmt.Errorf("%w: 動画ファイルがありません", ErrDownloadInvalid)
+	}
+	if stat, err := os.Stat(mediaPath); err != nil || stat.Size() == 0 {
+		return "", fmt.Errorf("%w: 動画ファイルが空です: %s", ErrDownloadInvalid, mediaPath)
+	}
+
+	// ffprobeがあればコンテナを読めるか確認
+	if ffprobe, err := exec.LookPath("ffprobe"); err == nil {
+		if output, err := exec.Command(ffprobe, "-v", "error", "-show_format", mediaPath).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("%w: ffprobe検証エラー: %v: %s", ErrDownloadInvalid, err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	return mediaPath, nil
+}
+
+// ファイルを移動 (別デバイスの場合はコピーしてからリネーム)
+// 移動先に既にファイルがある場合、overwrite なら置き換えを表示して上書きし、そうでなければ ErrDestinationExists を返す
+func moveFile(src, dst string, overwrite bool) error {
+	if _, err := os.Lstat(dst); err == nil {
+		if !overwrite {
+			return fmt.Errorf("%w: %s", ErrDestinationExists, dst)
+		}
+		fmt.Printf("既存のファイルを置き換えます: %s\n", dst)
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	// 移動先と同じディレクトリに一時ファイルとしてコピーし、リネームで置き換える
+	tmp := dst + ".moving"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ファイル移動エラー: %w", err)
+	}
+	return os.Remove(src)
+}
+
+// 同じファイルを指すパスか (絶対パスで比較)
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// ファイルをコピーしてディスクに書き出す
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("ファイル読み込みエラー: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("ファイル作成エラー: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("ファイルコピーエラー: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("ファイル書き込みエラー: %w", err)
+	}
+	return out.Close()
+}
+
+// ファイル名に使えない文字を置き換え
+func sanitizeFileName(name string) string {
+	replacer := strings.NewReplacer(
+		"/", "_", "\\", "_", ":", "_", "*", "_",
+		"?", "_", "\"", "_", "<", "_", ">", "_", "|", "_",
+	)
+	return strings.TrimSpace(replacer.Replace(name))
+}
+
+// シリーズ名のサブディレクトリに保存するダウンローダーを作成
+// (tvshow.nfo や poster.jpg がシリーズ単位のため、複数シリーズをまとめて扱う場合に使う)
+func (d *TVerDownloader) ForSeries(seriesTitle string) (*TVerDownloader, error) {
+	dirName := sanitizeFileName(seriesTitle)
+	if dirName == "" {
+		dirName = "不明な番組"
+	}
+
+	// 作業ディレクトリは番組ごとに分けず、元の出力ディレクトリのものを使う
+	seriesDownloader := *d
+	seriesDownloader.WorkDir = d.workDir()
+	seriesDownloader.OutputDir = filepath.Join(d.OutputDir, dirName)
+	if err := os.MkdirAll(seriesDownloader.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("出力ディレクトリ作成エラー: %w", err)
+	}
+	return &seriesDownloader, nil
+}
+
+// 検証済みの動画と付随ファイルを出力ディレクトリへ移動し、動画のパスを返す
+// (同名の動画を上書きするのは置き換え対象の replacePath と同じ場合のみ)
+func (d *TVerDownloader) finalizeDownload(jobDir, mediaPath, replacePath string) (string, error) {
+	entries, err := os.ReadDir(jobDir)
+	if err != nil {
+		return "", fmt.Errorf("作業ディレクトリ読み込みエラー: %w", err)
+	}
+
+	// 付随ファイルを移動する前に、動画を上書きしてしまわないか確認する
+	finalPath := filepath.Join(d.OutputDir, filepath.Base(mediaPath))
+	replace := samePath(finalPath, replacePath)
+	if _, err := os.Lstat(finalPath); err == nil && !replace {
+		return "", fmt.Errorf("%w: %s", ErrDestinationExists, finalPath)
+	}
+
+	// 動画が見えた時点で付随ファイルが揃っているよう、動画は最後に移動する
+	// (付随ファイルは動画と同じ名前で作られるため、以前のものが残っていれば置き換える)
+	for _, entry := range entries {
+		src := filepath.Join(jobDir, entry.Name())
+		if entry.IsDir() || src == mediaPath {
+			continue
+		}
+		if err := moveFile(src, filepath.Join(d.OutputDir, entry.Name()), true); err != nil {
+			return "", fmt.Errorf("付随ファイル移動エラー: %w", err)
+		}
+	}
+
+	if err := moveFile(mediaPath, finalPath, replace); err != nil {
+		return "", fmt.Errorf("動画ファイル移動エラー: %w", err)
+	}
+
+	fmt.Printf("保存先: %s\n", finalPath)
+	return finalPath, nil
+}
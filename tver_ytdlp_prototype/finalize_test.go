@@ -0,0 +1,180 @@
+// finalize_test.go
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ファイルを作成 (内容が空なら空ファイル)
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateDownload(t *testing.T) {
+	// ffprobe の有無で結果が変わらないようにする
+	t.Setenv("PATH", "")
+
+	cases := []struct {
+		name    string
+		files   map[string]string
+		want    string
+		invalid bool
+	}{
+		{"動画と付随ファイル", map[string]string{"a.mp4": "video", "a.info.json": "{}", "a.ja.srt": "1"}, "a.mp4", false},
+		{"動画なし", map[string]string{"a.info.json": "{}"}, "", true},
+		{"一時ファイルが残っている", map[string]string{"a.mp4": "video", "a.f137.mp4.part": "x"}, "", true},
+		{"動画が複数", map[string]string{"a.mp4": "video", "a.mkv": "video"}, "", true},
+		{"空の動画", map[string]string{"a.ts": ""}, "", true},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		for name, content := range c.files {
+			writeTestFile(t, filepath.Join(dir, name), content)
+		}
+		got, err := validateDownload(dir)
+		if c.invalid {
+			if !errors.Is(err, ErrDownloadInvalid) {
+				t.Errorf("%s: err = %v, want ErrDownloadInvalid", c.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got != filepath.Join(dir, c.want) {
+			t.Errorf("%s: 動画 = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	cases := []struct {
+		name      string
+		existing  bool
+		overwrite bool
+		wantErr   error
+		want      string
+	}{
+		{"移動先なし", false, false, nil, "new"},
+		{"上書きしない", true, false, ErrDestinationExists, "old"},
+		{"上書きする", true, true, nil, "new"},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "src.mp4"), filepath.Join(dir, "dst.mp4")
+		writeTestFile(t, src, "new")
+		if c.existing {
+			writeTestFile(t, dst, "old")
+		}
+
+		err := moveFile(src, dst, c.overwrite)
+		if !errors.Is(err, c.wantErr) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.wantErr)
+		}
+		if data, _ := os.ReadFile(dst); string(data) != c.want {
+			t.Errorf("%s: 移動先の内容 = %q, want %q", c.name, data, c.want)
+		}
+		if _, err := os.Stat(src); (err == nil) != (c.wantErr != nil) {
+			t.Errorf("%s: 移動元の有無が不正です: %v", c.name, err)
+		}
+	}
+}
+
+func TestFinalizeDownload(t *testing.T) {
+	cases := []struct {
+		name     string
+		existing bool
+		replace  bool
+		wantErr  error
+	}{
+		{"新規", false, false, nil},
+		{"同名の動画を残す", true, false, ErrDestinationExists},
+		{"置き換え対象の動画", true, true, nil},
+	}
+	for _, c := range cases {
+		jobDir, outputDir := t.TempDir(), t.TempDir()
+		mediaPath := filepath.Join(jobDir, "a.mp4")
+		writeTestFile(t, mediaPath, "new")
+		writeTestFile(t, filepath.Join(jobDir, "a.info.json"), "{}")
+		finalPath := filepath.Join(outputDir, "a.mp4")
+		if c.existing {
+			writeTestFile(t, finalPath, "old")
+		}
+		var replacePath string
+		if c.replace {
+			replacePath = finalPath
+		}
+
+		d := NewTVerDownloader(outputDir)
+		got, err := d.finalizeDownload(jobDir, mediaPath, replacePath)
+		if !errors.Is(err, c.wantErr) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.wantErr)
+			continue
+		}
+		if err != nil {
+			// 付随ファイルも動画も作業ディレクトリに残る
+			if _, err := os.Stat(mediaPath); err != nil {
+				t.Errorf("%s: 作業ディレクトリの動画がありません: %v", c.name, err)
+			}
+			if _, err := os.Stat(filepath.Join(outputDir, "a.info.json")); err == nil {
+				t.Errorf("%s: 付随ファイルが移動されています", c.name)
+			}
+			continue
+		}
+		if got != finalPath {
+			t.Errorf("%s: 保存先 = %s, want %s", c.name, got, finalPath)
+		}
+		if data, _ := os.ReadFile(finalPath); string(data) != "new" {
+			t.Errorf("%s: 動画の内容 = %q, want new", c.name, data)
+		}
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"out/a.mp4", "out/a.mp4", true},
+		{"out/a.mp4", "./out/../out/a.mp4", true},
+		{"out/a.mp4", "out/b.mp4", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		if got := samePath(c.a, c.b); got != c.want {
+			t.Errorf("samePath(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestForSeriesWorkDir(t *testing.T) {
+	out := t.TempDir()
+	d := NewTVerDownloader(out)
+	if got, want := d.workDir(), filepath.Join(out, ".tver_ytdlp_work"); got != want {
+		t.Errorf("workDir = %s, want %s", got, want)
+	}
+
+	// 番組ごとの出力ディレクトリに分けても、作業ディレクトリは元の出力ディレクトリのものを使う
+	series, err := d.ForSeries("番組A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.OutputDir != filepath.Join(out, "番組A") || series.workDir() != d.workDir() {
+		t.Errorf("OutputDir = %s, workDir = %s", series.OutputDir, series.workDir())
+	}
+
+	d.WorkDir = filepath.Join(t.TempDir(), "work")
+	if series, err = d.ForSeries("番組A"); err != nil {
+		t.Fatal(err)
+	}
+	if series.workDir() != d.WorkDir {
+		t.Errorf("--work-dir 指定時: workDir = %s", series.workDir())
+	}
+}
@@ -0,0 +1,148 @@
+// geoip.go
+package main
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 同梱の日本のIPアドレス範囲リスト (tver_ytdlp_prototype から実行する前提の相対パス)
+const defaultJPIPList = "../resources/geoip/jp.csv"
+
+// 日本のIPアドレスを装うために付与するヘッダー
+var forwardedHeaders = []string{
+	"Forwarded",
+	"Forwarded-For",
+	"X-Forwarded",
+	"X-Forwarded-For",
+	"X-Originating-IP",
+}
+
+// IPv4アドレス範囲 (両端を含む)
+type ipRange struct {
+	start uint32
+	end   uint32
+}
+
+// 範囲内のホストアドレス数 (ネットワークアドレスとブロードキャストアドレスを除く)
+func (r ipRange) hosts() uint64 {
+	if r.end-r.start < 2 {
+		return 0
+	}
+	return uint64(r.end - r.start - 1)
+}
+
+// 日本に割り当てられたIPアドレスの集合
+type JPIPPool struct {
+	ranges     []ipRange
+	cumulative []uint64 // ranges[i]までのホストアドレス数の累計
+	total      uint64
+}
+
+// CSV(start,end のヘッダー付き)からIPアドレスの集合を読み込む
+func LoadJPIPPool(path string) (*JPIPPool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("IPアドレスリスト読み込みエラー: %w", err)
+	}
+	defer file.Close()
+
+	return parseJPIPPool(file)
+}
+
+func parseJPIPPool(r io.Reader) (*JPIPPool, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+
+	pool := &JPIPPool{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("IPアドレスリスト解析エラー: %w", err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "start") {
+			continue
+		}
+
+		start, err := parseIPv4(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("IPアドレスリスト解析エラー (%d行目): %w", line, err)
+		}
+		end, err := parseIPv4(record[1])
+		if err != nil {
+			return nil, fmt.Errorf("IPアドレスリスト解析エラー (%d行目): %w", line, err)
+		}
+		if end < start {
+			return nil, fmt.Errorf("IPアドレスリスト解析エラー (%d行目): 範囲の終端が始端より前です", line)
+		}
+
+		r := ipRange{start: start, end: end}
+		if r.hosts() == 0 {
+			continue
+		}
+		pool.total += r.hosts()
+		pool.ranges = append(pool.ranges, r)
+		pool.cumulative = append(pool.cumulative, pool.total)
+	}
+
+	if pool.total == 0 {
+		return nil, errors.New("IPアドレスリストに有効な範囲がありません")
+	}
+	return pool, nil
+}
+
+// IPv4アドレスを数値に変換
+func parseIPv4(s string) (uint32, error) {
+	ip := net.ParseIP(strings.TrimSpace(s)).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("IPv4アドレスではありません: %s", s)
+	}
+	return binary.BigEndian.Uint32(ip), nil
+}
+
+// 全範囲のホストアドレスから一様にランダムな1件を選ぶ
+func (p *JPIPPool) Random(rng *rand.Rand) string {
+	n := uint64(rng.Int63n(int64(p.total)))
+	i := sort.Search(len(p.cumulative), func(i int) bool { return p.cumulative[i] > n })
+
+	offset := n
+	if i > 0 {
+		offset -= p.cumulative[i-1]
+	}
+	addr := p.ranges[i].start + 1 + uint32(offset)
+
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, addr)
+	return ip.String()
+}
+
+// 日本のIPアドレスを装うヘッダーを付与
+func setForwardedHeaders(header http.Header, ip string) {
+	for _, name := range forwardedHeaders {
+		header.Set(name, ip)
+	}
+}
+
+// yt-dlpに日本のIPアドレスを装うヘッダーを渡す引数を構築
+func (d *TVerDownloader) forwardedIPArgs() []string {
+	if d.ForwardedIP == "" {
+		return nil
+	}
+	var args []string
+	for _, name := range forwardedHeaders {
+		args = append(args, "--add-header", name+":"+d.ForwardedIP)
+	}
+	return args
+}
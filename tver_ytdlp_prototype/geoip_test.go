@@ -0,0 +1,115 @@
+// geoip_test.go
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseJPIPPool(t *testing.T) {
+	pool, err := parseJPIPPool(strings.NewReader("start,end\n1.0.16.0,1.0.31.255\n10.0.0.0,10.0.0.1\n10.0.1.0,10.0.1.3"))
+	if err != nil {
+		t.Fatalf("parseJPIPPool: %v", err)
+	}
+	// 10.0.0.0/31 はホストアドレスがないため除外される
+	if len(pool.ranges) != 2 {
+		t.Fatalf("ranges = %d, want 2", len(pool.ranges))
+	}
+	if want := uint64(4094 + 2); pool.total != want {
+		t.Errorf("total = %d, want %d", pool.total, want)
+	}
+}
+
+func TestParseJPIPPoolInvalid(t *testing.T) {
+	cases := map[string]string{
+		"不正なアドレス": "start,end\n1.0.16.0,foo\n",
+		"IPv6":    "start,end\n::1,::2\n",
+		"逆順の範囲":   "start,end\n1.0.31.255,1.0.16.0\n",
+		"列数不足":    "start,end\n1.0.16.0\n",
+		"空":       "start,end\n",
+	}
+	for name, input := range cases {
+		if _, err := parseJPIPPool(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: エラーになるべき入力が受理されました", name)
+		}
+	}
+}
+
+func TestJPIPPoolRandom(t *testing.T) {
+	pool, err := parseJPIPPool(strings.NewReader("start,end\n192.168.0.0,192.168.0.3\n192.168.1.0,192.168.1.255"))
+	if err != nil {
+		t.Fatalf("parseJPIPPool: %v", err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+	const samples = 25600
+	for i := 0; i < samples; i++ {
+		ip := pool.Random(rng)
+		switch {
+		case ip == "192.168.0.1" || ip == "192.168.0.2":
+			counts["small"]++
+		case strings.HasPrefix(ip, "192.168.1."):
+			if ip == "192.168.1.0" || ip == "192.168.1.255" {
+				t.Fatalf("ネットワーク/ブロードキャストアドレスが選ばれました: %s", ip)
+			}
+			counts["large"]++
+		default:
+			t.Fatalf("範囲外のアドレスが選ばれました: %s", ip)
+		}
+	}
+
+	// 範囲の大きさ (2 : 254) に比例して選ばれる
+	want := samples * 2 / 256
+	if got := counts["small"]; got < want/2 || got > want*2 {
+		t.Errorf("small = %d, want about %d", got, want)
+	}
+}
+
+func TestLoadBundledJPIPPool(t *testing.T) {
+	pool, err := LoadJPIPPool(defaultJPIPList)
+	if err != nil {
+		t.Fatalf("LoadJPIPPool: %v", err)
+	}
+	if len(pool.ranges) == 0 {
+		t.Fatal("範囲が読み込まれていません")
+	}
+	if ip := pool.Random(rand.New(rand.NewSource(1))); ip == "" {
+		t.Error("アドレスを選べませんでした")
+	}
+}
+
+func TestForwardedHeadersSent(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	client := NewTVerClient()
+	client.ForwardedIP = "1.0.16.1"
+	if _, err := client.get(server.URL); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	for _, name := range forwardedHeaders {
+		if v := got.Get(name); v != "1.0.16.1" {
+			t.Errorf("%s = %q, want 1.0.16.1", name, v)
+		}
+	}
+}
+
+func TestForwardedIPArgs(t *testing.T) {
+	d := NewTVerDownloader(".")
+	if args := d.forwardedIPArgs(); len(args) != 0 {
+		t.Errorf("未設定時の引数 = %v, want なし", args)
+	}
+	d.ForwardedIP = "1.0.16.1"
+	args := d.forwardedIPArgs()
+	if len(args) != 2*len(forwardedHeaders) || args[0] != "--add-header" {
+		t.Errorf("引数 = %v", args)
+	}
+}
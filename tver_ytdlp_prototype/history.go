@@ -0,0 +1,249 @@
+// history.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PowerShell版の history.csv の列 (この順で書き出す)
+var historyColumns = []string{
+	"videoPage", "videoSeriesPage", "genre", "series", "season", "title", "media",
+	"broadcastDate", "downloadDate", "videoDir", "videoName", "videoPath", "videoValidated",
+}
+
+// history.csv の1行
+type HistoryRecord struct {
+	VideoPage       string
+	VideoSeriesPage string
+	Genre           string
+	Series          string
+	Season          string
+	Title           string
+	Media           string
+	BroadcastDate   string
+	DownloadDate    time.Time
+	VideoDir        string
+	VideoName       string
+	VideoPath       string
+	VideoValidated  int
+}
+
+// history.csv の読み込み結果
+type HistoryFile struct {
+	Records []HistoryRecord
+	Dropped int // 壊れていたため除いた行数
+}
+
+// history.csv を読み込み、壊れた行を除く (Optimize-HistoryFile 相当)
+//
+// NULL文字を含む行と、videoValidatedが整数でない・downloadDateが日時でない行を除く。
+func ReadHistoryFile(path string) (*HistoryFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("履歴ファイル読み込みエラー: %w", err)
+	}
+	return parseHistoryCSV(data)
+}
+
+func parseHistoryCSV(data []byte) (*HistoryFile, error) {
+	header, rows, dropped, err := readCSVRecords(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(header, "videoPage") {
+		return nil, errors.New("history.csv の形式ではありません")
+	}
+
+	history := &HistoryFile{Dropped: dropped}
+	for _, row := range rows {
+		record, ok := historyRecordFromRow(row)
+		if !ok {
+			history.Dropped++
+			continue
+		}
+		history.Records = append(history.Records, record)
+	}
+	return history, nil
+}
+
+// 列名→値のマップから1行を作成 (必須の列が解析できなければfalse)
+func historyRecordFromRow(row map[string]string) (HistoryRecord, bool) {
+	validated, err := strconv.Atoi(row["videoValidated"])
+	if err != nil {
+		return HistoryRecord{}, false
+	}
+	downloadDate, err := time.ParseInLocation(historyTimeLayout, row["downloadDate"], jst)
+	if err != nil {
+		return HistoryRecord{}, false
+	}
+	return HistoryRecord{
+		VideoPage:       row["videoPage"],
+		VideoSeriesPage: row["videoSeriesPage"],
+		Genre:           row["genre"],
+		Series:          row["series"],
+		Season:          row["season"],
+		Title:           row["title"],
+		Media:           row["media"],
+		BroadcastDate:   row["broadcastDate"],
+		DownloadDate:    downloadDate,
+		VideoDir:        row["videoDir"],
+		VideoName:       row["videoName"],
+		VideoPath:       row["videoPath"],
+		VideoValidated:  validated,
+	}, true
+}
+
+// 列の順に値を並べる
+func (r HistoryRecord) values() []string {
+	return []string{
+		r.VideoPage, r.VideoSeriesPage, r.Genre, r.Series, r.Season, r.Title, r.Media,
+		r.BroadcastDate, r.DownloadDate.In(jst).Format(historyTimeLayout),
+		r.VideoDir, r.VideoName, r.VideoPath, strconv.Itoa(r.VideoValidated),
+	}
+}
+
+// 保持期間を過ぎた行を除く (Limit-HistoryFile 相当)
+func LimitHistoryRecords(records []HistoryRecord, retention time.Duration, now time.Time) []HistoryRecord {
+	threshold := now.Add(-retention)
+	var kept []HistoryRecord
+	for _, record := range records {
+		if record.DownloadDate.After(threshold) {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// videoPageごとに最新の行だけを残し、最新が検証失敗のものは除く (Repair-HistoryFile 相当)
+func LatestHistoryRecords(records []HistoryRecord) []HistoryRecord {
+	latest := make(map[string]int)
+	for i, record := range records {
+		if j, ok := latest[record.VideoPage]; !ok || !record.DownloadDate.Before(records[j].DownloadDate) {
+			latest[record.VideoPage] = i
+		}
+	}
+
+	var kept []HistoryRecord
+	for i, record := range records {
+		if latest[record.VideoPage] == i && record.VideoValidated != validationFailed {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// history.csv を書き出す (PowerShellのExport-Csvと同じく全項目をダブルクォートで囲む)
+func WriteHistoryFile(path string, records []HistoryRecord) error {
+	var buf bytes.Buffer
+	writeQuotedCSVLine(&buf, historyColumns)
+	for _, record := range records {
+		writeQuotedCSVLine(&buf, record.values())
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("履歴ファイル書き込みエラー: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("履歴ファイル書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+func writeQuotedCSVLine(buf *bytes.Buffer, values []string) {
+	for i, value := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('"')
+		buf.WriteString(strings.ReplaceAll(value, `"`, `""`))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('\n')
+}
+
+// history.csv の行をカタログに取り込む
+func (c *Catalog) ImportHistory(records []HistoryRecord, source string) (*CatalogImportResult, error) {
+	result := &CatalogImportResult{Format: "history"}
+	err := c.update(func(tx catalogTx) error {
+		for _, record := range records {
+			if err := tx.importHistoryRecord(record, source, result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// カタログのダウンロード試行を history.csv の行として書き出す (記録した順)
+//
+// 番組情報はダウンロードした時点のもの (取り込んだ行はその行の値) を使うため、取り込んだ履歴はそのまま書き出される。
+// 動画を残さなかった失敗はPowerShell版の履歴に相当する行がないため含めない。
+// このツールでダウンロードした動画のvideoPathは出力ディレクトリからの相対パスにする。
+func (c *Catalog) HistoryRecords(outputDir string) []HistoryRecord {
+	downloads := c.queryDownloads(`NOT (status = ? AND validated <> ?)`, downloadFailed, validationFailed)
+
+	records := make([]HistoryRecord, 0, len(downloads))
+	for _, download := range downloads {
+		record := HistoryRecord{
+			VideoPage:       download.URL,
+			VideoSeriesPage: download.SeriesURL,
+			Genre:           download.Genre,
+			Series:          download.Series,
+			Season:          download.Season,
+			Title:           download.Title,
+			Media:           download.Media,
+			BroadcastDate:   download.BroadcastDate,
+			DownloadDate:    download.FinishedAt,
+			VideoDir:        download.VideoDir,
+			VideoName:       download.VideoName,
+			VideoPath:       download.VideoPath,
+			VideoValidated:  download.Validated,
+		}
+		if download.Source == "" && download.VideoPath != "" {
+			if dir, err := filepath.Abs(download.VideoDir); err == nil {
+				record.VideoDir = filepath.ToSlash(dir)
+			}
+			if rel, err := filepath.Rel(outputDir, download.VideoPath); err == nil {
+				record.VideoPath = filepath.ToSlash(rel)
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// 保持期間を過ぎたダウンロード試行をカタログから削除し、削除件数を返す
+func (c *Catalog) TrimDownloads(retention time.Duration, now time.Time) (int, error) {
+	res, err := c.db.Exec(`DELETE FROM downloads WHERE finished_at <= ?`, catalogTime(now.Add(-retention)))
+	if err != nil {
+		return 0, fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	removed, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	return int(removed), nil
+}
+
+// 保持日数の指定を解析 (0以下は無効)
+func parseRetentionDays(value string) (time.Duration, error) {
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return 0, fmt.Errorf("保持日数は1以上の整数で指定してください: %s", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
@@ -0,0 +1,406 @@
+// history_query.go
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 統計で番組・放送局ごとに表示する件数
+const historyStatsTop = 20
+
+// 検索結果のエピソードと最新のダウンロード試行
+type HistoryMatch struct {
+	Episode CatalogEpisode
+	Latest  *CatalogDownload // ダウンロード試行がなければnil
+}
+
+// エピソードID・タイトル・番組名・放送局・URLで部分一致検索 (大文字小文字を区別しない)
+func (c *Catalog) Search(query string) []HistoryMatch {
+	rows, err := c.db.Query(`SELECT ` + qualifyColumns("e", catalogEpisodeColumns) + `,
+			l.id, COALESCE(l.url, ''), COALESCE(l.video_path, '')
+		FROM episodes e ` + latestDownloadJoin)
+	if err != nil {
+		logCatalogError(err)
+		return nil
+	}
+
+	query = strings.ToLower(query)
+	var matches []HistoryMatch
+	var latestIDs []sql.NullInt64
+	for rows.Next() {
+		var latestID sql.NullInt64
+		var url, videoPath string
+		episode, err := scanCatalogEpisode(rows, &latestID, &url, &videoPath)
+		if err != nil {
+			rows.Close()
+			logCatalogError(err)
+			return nil
+		}
+		fields := []string{episode.ID, episode.Title, episode.SeriesTitle, episode.SeasonTitle, episode.Broadcaster}
+		if latestID.Valid {
+			fields = append(fields, url, videoPath)
+		}
+		if !strings.Contains(strings.ToLower(strings.Join(fields, "\n")), query) {
+			continue
+		}
+		matches = append(matches, HistoryMatch{Episode: episode})
+		latestIDs = append(latestIDs, latestID)
+	}
+	rows.Close()
+
+	for i, latestID := range latestIDs {
+		if !latestID.Valid {
+			continue
+		}
+		if downloads := c.queryDownloads(`id = ?`, latestID.Int64); len(downloads) > 0 {
+			matches[i].Latest = &downloads[0]
+		}
+	}
+
+	// 最近ダウンロードしたものから、未ダウンロードのものは最後に番組名順
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if (a.Latest == nil) != (b.Latest == nil) {
+			return a.Latest != nil
+		}
+		if a.Latest != nil && !a.Latest.FinishedAt.Equal(b.Latest.FinishedAt) {
+			return a.Latest.FinishedAt.After(b.Latest.FinishedAt)
+		}
+		return a.Episode.SeriesTitle+a.Episode.Title < b.Episode.SeriesTitle+b.Episode.Title
+	})
+	return matches
+}
+
+// 条件に合うダウンロード試行 (記録した順)
+func (c *Catalog) queryDownloads(where string, args ...any) []CatalogDownload {
+	rows, err := c.db.Query(`SELECT `+catalogDownloadColumns+` FROM downloads WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		logCatalogError(err)
+		return nil
+	}
+	defer rows.Close()
+
+	var downloads []CatalogDownload
+	for rows.Next() {
+		download, err := scanCatalogDownload(rows)
+		if err != nil {
+			logCatalogError(err)
+			return nil
+		}
+		downloads = append(downloads, download)
+	}
+	return downloads
+}
+
+// エピソードのダウンロード試行 (古い順)
+func (c *Catalog) EpisodeDownloads(episodeID string) []CatalogDownload {
+	return c.queryDownloads(`episode_id = ?`, episodeID)
+}
+
+// エピソードの情報を取得
+func (c *Catalog) Episode(episodeID string) (CatalogEpisode, bool) {
+	episode, err := scanCatalogEpisode(c.db.QueryRow(`SELECT `+catalogEpisodeColumns+` FROM episodes WHERE id = ?`, episodeID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return CatalogEpisode{}, false
+	}
+	if err != nil {
+		logCatalogError(err)
+		return CatalogEpisode{}, false
+	}
+	return episode, true
+}
+
+// エピソードのダウンロード試行を削除して再ダウンロードできるようにし、削除件数を返す
+// (エピソード情報は残す)
+func (c *Catalog) Forget(episodeID string) (int, error) {
+	res, err := c.db.Exec(`DELETE FROM downloads WHERE episode_id = ?`, episodeID)
+	if err != nil {
+		return 0, fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	removed, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("カタログ書き込みエラー: %w", err)
+	}
+	return int(removed), nil
+}
+
+// カタログに加えて discover・series watch の記録からもエピソードを外し、削除件数を返す
+// (discover は独自の記録で、series watch は前回の状態にあるものを既知としてダウンロード済みを判定するため)
+func ForgetEpisode(catalog *Catalog, episodeID, discoverStatePath, watchStatePath string) (int, error) {
+	removed, err := catalog.Forget(episodeID)
+	if err != nil {
+		return 0, err
+	}
+
+	discover, err := LoadDiscoverState(discoverStatePath)
+	if err != nil {
+		return removed, err
+	}
+	if _, ok := discover.Downloaded[episodeID]; ok {
+		delete(discover.Downloaded, episodeID)
+		if err := discover.Save(discoverStatePath); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	snapshot, err := LoadWatchSnapshot(watchStatePath)
+	if err != nil {
+		return removed, err
+	}
+	if snapshot.Forget(episodeID) {
+		if err := snapshot.Save(watchStatePath); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// ダウンロード数・失敗数・容量の集計
+type HistoryStatsGroup struct {
+	Name      string
+	Succeeded int
+	Failed    int
+	Skipped   int
+	Bytes     int64
+}
+
+// 失敗率 (スキップは除く, 試行がなければ0)
+func (g *HistoryStatsGroup) FailureRate() float64 {
+	if attempts := g.Succeeded + g.Failed; attempts > 0 {
+		return float64(g.Failed) / float64(attempts)
+	}
+	return 0
+}
+
+func (g *HistoryStatsGroup) add(download CatalogDownload) {
+	switch download.Status {
+	case downloadSucceeded:
+		g.Succeeded++
+		g.Bytes += download.Bytes
+	case downloadFailed:
+		g.Failed++
+	case downloadSkipped:
+		g.Skipped++
+	}
+}
+
+// ダウンロード履歴の統計
+type HistoryStats struct {
+	Since          time.Time // 集計の開始日時 (ゼロ値なら全期間)
+	Total          HistoryStatsGroup
+	PerDay         []*HistoryStatsGroup // 日付順
+	PerBroadcaster []*HistoryStatsGroup // ダウンロード数の多い順
+	PerSeries      []*HistoryStatsGroup // ダウンロード数の多い順
+
+	// このツールでダウンロードしたものの所要時間 (取り込んだ履歴には記録がない)
+	TimedDownloads  int
+	AverageDuration time.Duration
+}
+
+// since以降のダウンロード試行を集計 (sinceがゼロ値なら全期間)
+func (c *Catalog) Stats(since time.Time) *HistoryStats {
+	stats := &HistoryStats{Since: since}
+	perDay := make(map[string]*HistoryStatsGroup)
+	perBroadcaster := make(map[string]*HistoryStatsGroup)
+	perSeries := make(map[string]*HistoryStatsGroup)
+	group := func(groups map[string]*HistoryStatsGroup, name string) *HistoryStatsGroup {
+		if name == "" {
+			name = "(不明)"
+		}
+		g, ok := groups[name]
+		if !ok {
+			g = &HistoryStatsGroup{Name: name}
+			groups[name] = g
+		}
+		return g
+	}
+
+	rows, err := c.db.Query(`SELECT `+qualifyColumns("d", catalogDownloadColumns)+`,
+			COALESCE(e.broadcaster, ''), COALESCE(e.series_title, '')
+		FROM downloads d LEFT JOIN episodes e ON e.id = d.episode_id
+		WHERE d.finished_at >= ? ORDER BY d.id`, catalogTime(since))
+	if err != nil {
+		logCatalogError(err)
+		return stats
+	}
+	defer rows.Close()
+
+	var totalDuration time.Duration
+	for rows.Next() {
+		var broadcaster, series string
+		download, err := scanCatalogDownload(rows, &broadcaster, &series)
+		if err != nil {
+			logCatalogError(err)
+			break
+		}
+		stats.Total.add(download)
+		group(perDay, download.FinishedAt.Local().Format("2006-01-02")).add(download)
+		group(perBroadcaster, broadcaster).add(download)
+		group(perSeries, series).add(download)
+
+		if download.Status == downloadSucceeded && download.Source == "" && download.FinishedAt.After(download.StartedAt) {
+			stats.TimedDownloads++
+			totalDuration += download.FinishedAt.Sub(download.StartedAt)
+		}
+	}
+	if stats.TimedDownloads > 0 {
+		stats.AverageDuration = totalDuration / time.Duration(stats.TimedDownloads)
+	}
+
+	stats.PerDay = sortedStatsGroups(perDay, func(a, b *HistoryStatsGroup) bool { return a.Name < b.Name })
+	byCount := func(a, b *HistoryStatsGroup) bool {
+		if a.Succeeded != b.Succeeded {
+			return a.Succeeded > b.Succeeded
+		}
+		return a.Name < b.Name
+	}
+	stats.PerBroadcaster = sortedStatsGroups(perBroadcaster, byCount)
+	stats.PerSeries = sortedStatsGroups(perSeries, byCount)
+	return stats
+}
+
+func sortedStatsGroups(groups map[string]*HistoryStatsGroup, less func(a, b *HistoryStatsGroup) bool) []*HistoryStatsGroup {
+	result := make([]*HistoryStatsGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool { return less(result[i], result[j]) })
+	return result
+}
+
+// 検索結果を表示
+func displayHistoryMatches(query string, matches []HistoryMatch) {
+	fmt.Printf("\n=== 検索結果: %q (%d件) ===\n", query, len(matches))
+	for _, match := range matches {
+		episode := match.Episode
+		status, finishedAt := "未ダウンロード", ""
+		if match.Latest != nil {
+			status = match.Latest.Status
+			finishedAt = match.Latest.FinishedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%s %s %s [%s] %s\n", episode.ID, episode.SeriesTitle, episode.Title, status, finishedAt)
+	}
+	fmt.Println("==================")
+}
+
+// エピソードの情報とダウンロード試行を表示
+func displayHistoryEpisode(episode CatalogEpisode, downloads []CatalogDownload) {
+	fmt.Println("\n=== エピソード ===")
+	fmt.Printf("ID: %s\n", episode.ID)
+	fmt.Printf("タイトル: %s\n", episode.Title)
+	fmt.Printf("シリーズ: %s (%s)\n", episode.SeriesTitle, episode.SeriesID)
+	if episode.SeasonTitle != "" {
+		fmt.Printf("シーズン: %s\n", episode.SeasonTitle)
+	}
+	if episode.EpisodeNumber > 0 {
+		fmt.Printf("エピソード番号: %d\n", episode.EpisodeNumber)
+	}
+	fmt.Printf("放送局: %s\n", episode.Broadcaster)
+	if episode.BroadcastDate != "" {
+		fmt.Printf("放送日: %s\n", episode.BroadcastDate)
+	}
+	if episode.EndAt != 0 {
+		fmt.Printf("配信終了: %s\n", formatEndAt(time.Unix(episode.EndAt, 0)))
+	}
+	if episode.Version > 0 {
+		fmt.Printf("バージョン: %d\n", episode.Version)
+	}
+
+	fmt.Printf("\n--- ダウンロード試行 (%d件) ---\n", len(downloads))
+	for _, download := range downloads {
+		fmt.Printf("[%s] %s", download.FinishedAt.Local().Format("2006-01-02 15:04:05"), download.Status)
+		if download.Version > 0 {
+			fmt.Printf(" バージョン%d", download.Version)
+		}
+		if download.Source != "" {
+			fmt.Printf(" (%sから取り込み)", download.Source)
+		} else if download.FinishedAt.After(download.StartedAt) {
+			fmt.Printf(" (所要時間: %v)", download.FinishedAt.Sub(download.StartedAt).Round(time.Second))
+		}
+		fmt.Println()
+		if download.VideoPath != "" {
+			fmt.Printf("    保存先: %s", download.VideoPath)
+			if download.Bytes > 0 {
+				fmt.Printf(" (%s)", formatBytes(download.Bytes))
+			}
+			fmt.Println()
+		}
+		if download.Error != "" {
+			fmt.Printf("    エラー: %s\n", download.Error)
+		}
+	}
+	fmt.Println("==================")
+}
+
+// 統計を表示
+func displayHistoryStats(stats *HistoryStats) {
+	period := "全期間"
+	if !stats.Since.IsZero() {
+		period = stats.Since.Local().Format("2006-01-02") + "以降"
+	}
+	total := stats.Total
+	fmt.Printf("\n=== ダウンロード統計 (%s) ===\n", period)
+	fmt.Printf("成功: %d件 / 失敗: %d件 (失敗率 %.1f%%) / スキップ: %d件\n",
+		total.Succeeded, total.Failed, total.FailureRate()*100, total.Skipped)
+	fmt.Printf("合計容量: %s\n", formatBytes(total.Bytes))
+	if stats.TimedDownloads > 0 {
+		fmt.Printf("平均所要時間: %v (%d件)\n", stats.AverageDuration.Round(time.Second), stats.TimedDownloads)
+	}
+
+	fmt.Println("\n--- 日別 ---")
+	for _, g := range stats.PerDay {
+		displayStatsGroup(g)
+	}
+	fmt.Println("\n--- 放送局別 ---")
+	for i, g := range stats.PerBroadcaster {
+		if i == historyStatsTop {
+			fmt.Printf("  ほか%d局\n", len(stats.PerBroadcaster)-historyStatsTop)
+			break
+		}
+		displayStatsGroup(g)
+	}
+	fmt.Println("\n--- 番組別 ---")
+	for i, g := range stats.PerSeries {
+		if i == historyStatsTop {
+			fmt.Printf("  ほか%d番組\n", len(stats.PerSeries)-historyStatsTop)
+			break
+		}
+		displayStatsGroup(g)
+	}
+	fmt.Println("==================")
+}
+
+func displayStatsGroup(g *HistoryStatsGroup) {
+	fmt.Printf("  %s: %d件", g.Name, g.Succeeded)
+	if g.Failed > 0 {
+		fmt.Printf(" / 失敗 %d件 (%.1f%%)", g.Failed, g.FailureRate()*100)
+	}
+	if g.Skipped > 0 {
+		fmt.Printf(" / スキップ %d件", g.Skipped)
+	}
+	if g.Bytes > 0 {
+		fmt.Printf(" / %s", formatBytes(g.Bytes))
+	}
+	fmt.Println()
+}
+
+// バイト数を読みやすい単位で表示
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", value, "KMGT"[exp])
+}
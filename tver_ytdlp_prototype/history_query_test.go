@@ -0,0 +1,98 @@
+// history_query_test.go
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestForgetEpisode(t *testing.T) {
+	cases := []struct {
+		name        string
+		catalog     bool // カタログにダウンロード試行がある
+		discover    bool // discover の記録にある
+		watch       bool // series watch の前回状態にある
+		wantRemoved int
+	}{
+		{"記録なし", false, false, false, 0},
+		{"カタログのみ", true, false, false, 1},
+		{"discoverのみ", false, true, false, 1},
+		{"series watchのみ", false, false, true, 1},
+		{"すべて", true, true, true, 3},
+	}
+	for _, tc := range cases {
+		dir := t.TempDir()
+		discoverPath := filepath.Join(dir, "discover_state.json")
+		watchPath := filepath.Join(dir, "watch_state.json")
+		catalog := newTestCatalog(t)
+
+		if tc.catalog {
+			if err := catalog.RecordDownload(CatalogDownload{EpisodeID: "ep1", Status: downloadSucceeded}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		discover := &DiscoverState{Downloaded: map[string]time.Time{"ep2": time.Now()}}
+		if tc.discover {
+			discover.Downloaded["ep1"] = time.Now()
+		}
+		if err := discover.Save(discoverPath); err != nil {
+			t.Fatal(err)
+		}
+		snapshot := &WatchSnapshot{Series: map[string]*WatchedSeries{
+			"sr1": {Episodes: map[string]WatchedEpisode{"ep2": {Title: "第2話"}}},
+		}}
+		if tc.watch {
+			snapshot.Series["sr1"].Episodes["ep1"] = WatchedEpisode{Title: "第1話"}
+		}
+		if err := snapshot.Save(watchPath); err != nil {
+			t.Fatal(err)
+		}
+
+		removed, err := ForgetEpisode(catalog, "ep1", discoverPath, watchPath)
+		if err != nil {
+			t.Fatalf("%s: ForgetEpisode: %v", tc.name, err)
+		}
+		if removed != tc.wantRemoved {
+			t.Errorf("%s: removed = %d, want %d", tc.name, removed, tc.wantRemoved)
+		}
+
+		if catalog.IsDownloaded("ep1") {
+			t.Errorf("%s: カタログでダウンロード済みのままです", tc.name)
+		}
+		discover, err = LoadDiscoverState(discoverPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := discover.Downloaded["ep1"]; ok {
+			t.Errorf("%s: discover の記録に残っています", tc.name)
+		}
+		snapshot, err = LoadWatchSnapshot(watchPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := snapshot.Series["sr1"].Episodes["ep1"]; ok {
+			t.Errorf("%s: series watch の前回状態に残っています", tc.name)
+		}
+		// 他のエピソードの記録は残す
+		if _, ok := discover.Downloaded["ep2"]; !ok {
+			t.Errorf("%s: discover の ep2 が消えました", tc.name)
+		}
+		if _, ok := snapshot.Series["sr1"].Episodes["ep2"]; !ok {
+			t.Errorf("%s: series watch の ep2 が消えました", tc.name)
+		}
+	}
+}
+
+func TestForgetEpisodeMissingStateFiles(t *testing.T) {
+	// 記録ファイルがなければ作成しない
+	dir := t.TempDir()
+	catalog := newTestCatalog(t)
+	removed, err := ForgetEpisode(catalog, "ep1", filepath.Join(dir, "discover_state.json"), filepath.Join(dir, "watch_state.json"))
+	if err != nil || removed != 0 {
+		t.Fatalf("ForgetEpisode = %d, %v", removed, err)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(matches) != 0 {
+		t.Errorf("記録ファイルが作成されました: %v", matches)
+	}
+}
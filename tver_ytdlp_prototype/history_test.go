@@ -0,0 +1,187 @@
+// history_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHistoryHeader = `"videoPage","videoSeriesPage","genre","series","season","title","media","broadcastDate","downloadDate","videoDir","videoName","videoPath","videoValidated"` + "\n"
+
+func TestParseHistoryCSV(t *testing.T) {
+	valid := `"https://tver.jp/episodes/ep1","https://tver.jp/series/sr1","ドラマ","番組","本編","第1話","局","3月17日(月)放送分","2025-03-17 21:00:00","V:/番組","第1話.mp4","番組/第1話.mp4","1"` + "\n"
+	cases := []struct {
+		name    string
+		input   string
+		records int
+		dropped int
+		wantErr bool
+	}{
+		{"正常", testHistoryHeader + valid, 1, 0, false},
+		{"BOM付き", "\uFEFF" + testHistoryHeader + valid, 1, 0, false},
+		{"NULL文字を含む行", testHistoryHeader + valid + "\x00\x00\x00\n", 1, 1, false},
+		{"videoValidatedが整数でない", testHistoryHeader + valid + `"https://tver.jp/episodes/ep2","","","","","","","","2025-03-17 21:00:00","","","","検証済"` + "\n", 1, 1, false},
+		{"downloadDateが日時でない", testHistoryHeader + valid + `"https://tver.jp/episodes/ep2","","","","","","","","2025/03/17","","","","0"` + "\n", 1, 1, false},
+		{"行なし", testHistoryHeader, 0, 0, false},
+		{"list.csv", `"episodeID","seriesID"` + "\n" + `"ep1","sr1"` + "\n", 0, 0, true},
+	}
+	for _, tc := range cases {
+		history, err := parseHistoryCSV([]byte(tc.input))
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: エラーになるべき入力が受理されました", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseHistoryCSV: %v", tc.name, err)
+			continue
+		}
+		if len(history.Records) != tc.records || history.Dropped != tc.dropped {
+			t.Errorf("%s: records = %d, dropped = %d, want %d, %d", tc.name, len(history.Records), history.Dropped, tc.records, tc.dropped)
+		}
+	}
+
+	history, err := parseHistoryCSV([]byte(testHistoryHeader + valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := history.Records[0]
+	if record.Series != "番組" || record.VideoValidated != validationOK ||
+		!record.DownloadDate.Equal(time.Date(2025, 3, 17, 21, 0, 0, 0, jst)) {
+		t.Errorf("record = %+v", record)
+	}
+}
+
+func TestLimitHistoryRecords(t *testing.T) {
+	now := time.Date(2025, 4, 30, 12, 0, 0, 0, jst)
+	records := []HistoryRecord{
+		{VideoPage: "old", DownloadDate: now.AddDate(0, 0, -31)},
+		{VideoPage: "boundary", DownloadDate: now.AddDate(0, 0, -30)},
+		{VideoPage: "recent", DownloadDate: now.AddDate(0, 0, -1)},
+		{VideoPage: "future", DownloadDate: now.Add(time.Hour)},
+	}
+	cases := []struct {
+		name      string
+		retention time.Duration
+		want      []string
+	}{
+		{"30日", 30 * 24 * time.Hour, []string{"recent", "future"}},
+		{"31日より長い", 32 * 24 * time.Hour, []string{"old", "boundary", "recent", "future"}},
+		{"1時間", time.Hour, []string{"future"}},
+	}
+	for _, tc := range cases {
+		kept := LimitHistoryRecords(records, tc.retention, now)
+		if got := historyVideoPages(kept); strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLatestHistoryRecords(t *testing.T) {
+	base := time.Date(2025, 4, 1, 0, 0, 0, 0, jst)
+	cases := []struct {
+		name    string
+		records []HistoryRecord
+		want    []string // 残る行の videoPage と downloadDate の日
+	}{
+		{"重複なし", []HistoryRecord{
+			{VideoPage: "a", DownloadDate: base},
+			{VideoPage: "b", DownloadDate: base},
+		}, []string{"a@1", "b@1"}},
+		{"新しい行を残す", []HistoryRecord{
+			{VideoPage: "a", DownloadDate: base.AddDate(0, 0, 1)},
+			{VideoPage: "a", DownloadDate: base},
+		}, []string{"a@2"}},
+		{"同じ日時なら後の行", []HistoryRecord{
+			{VideoPage: "a", DownloadDate: base, VideoName: "1"},
+			{VideoPage: "a", DownloadDate: base, VideoName: "2"},
+		}, []string{"a@1"}},
+		{"最新が検証失敗なら除く", []HistoryRecord{
+			{VideoPage: "a", DownloadDate: base, VideoValidated: validationOK},
+			{VideoPage: "a", DownloadDate: base.AddDate(0, 0, 1), VideoValidated: validationFailed},
+			{VideoPage: "b", DownloadDate: base, VideoValidated: validationFailed},
+		}, nil},
+		{"古い検証失敗は残らない", []HistoryRecord{
+			{VideoPage: "a", DownloadDate: base, VideoValidated: validationFailed},
+			{VideoPage: "a", DownloadDate: base.AddDate(0, 0, 1), VideoValidated: validationOK},
+		}, []string{"a@2"}},
+	}
+	for _, tc := range cases {
+		kept := LatestHistoryRecords(tc.records)
+		var got []string
+		for _, record := range kept {
+			got = append(got, record.VideoPage+"@"+record.DownloadDate.Format("2"))
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: %v, want %v", tc.name, got, tc.want)
+		}
+		if tc.name == "同じ日時なら後の行" && (len(kept) != 1 || kept[0].VideoName != "2") {
+			t.Errorf("%s: %+v", tc.name, kept)
+		}
+	}
+}
+
+func historyVideoPages(records []HistoryRecord) []string {
+	var pages []string
+	for _, record := range records {
+		pages = append(pages, record.VideoPage)
+	}
+	return pages
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	// 同じエピソードの行ごとに番組情報が異なる・エピソードのページでない行・対象外・検証失敗を含む
+	input := testHistoryHeader +
+		`"https://tver.jp/episodes/ep1","https://tver.jp/series/sr1","ドラマ","番組","本編","第1話","局","3月17日(月)放送分","2025-03-17 21:00:00","V:/番組","第1話.mp4","番組/第1話.mp4","1"` + "\n" +
+		`"https://tver.jp/episodes/ep1","https://tver.jp/series/sr1","バラエティ","番組(旧)","","第1話 再","局","2025年03月17日(月)放送","2025-03-18 21:00:00","V:/番組","第1話 再.mp4","番組/第1話 再.mp4","0"` + "\n" +
+		`"https://tver.jp/feature/f1","","特集","特集番組","","特集","","","2025-03-19 08:00:00","V:/特集","-- IGNORED --","-- IGNORED --","1"` + "\n" +
+		`"https://tver.jp/episodes/ep2","https://tver.jp/series/sr1","ドラマ","番組","本編","第2話","局","3月24日(月)放送分","2025-03-24 21:00:00","V:/番組","第2話.mp4","番組/第2話.mp4","3"` + "\n"
+
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "history.csv")
+	writeTestFile(t, inputPath, input)
+	history, err := ReadHistoryFile(inputPath)
+	if err != nil {
+		t.Fatalf("ReadHistoryFile: %v", err)
+	}
+
+	catalog := newTestCatalog(t)
+	result, err := catalog.ImportHistory(history.Records, "history.csv")
+	if err != nil {
+		t.Fatalf("ImportHistory: %v", err)
+	}
+	if result.Downloads != 4 || result.Duplicate != 0 || result.Invalid != 0 {
+		t.Errorf("1回目の取り込み = %+v", result)
+	}
+
+	// 取り込み後にAPIから取得したエピソード情報で上書きされても書き出す内容は変わらない
+	err = catalog.UpsertEpisodeDetail(&EpisodeDetail{ID: "ep1", SeriesID: "sr1", SeriesTitle: "新番組名", Title: "新タイトル", Broadcaster: "新局"})
+	if err != nil {
+		t.Fatalf("UpsertEpisodeDetail: %v", err)
+	}
+
+	// 同じファイルをもう一度取り込んでも重複しない
+	result, err = catalog.ImportHistory(history.Records, "history.csv")
+	if err != nil {
+		t.Fatalf("ImportHistory (2回目): %v", err)
+	}
+	if result.Downloads != 0 || result.Duplicate != 4 {
+		t.Errorf("2回目の取り込み = %+v", result)
+	}
+
+	outputPath := filepath.Join(dir, "exported.csv")
+	if err := WriteHistoryFile(outputPath, catalog.HistoryRecords(dir)); err != nil {
+		t.Fatalf("WriteHistoryFile: %v", err)
+	}
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != input {
+		t.Errorf("書き出した履歴が取り込んだものと異なります:\n%s\nwant:\n%s", output, input)
+	}
+}
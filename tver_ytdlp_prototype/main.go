@@ -3,9 +3,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -31,15 +35,59 @@ type YtdlpVideoInfo struct {
 	Webpage       string  `json:"webpage_url"`
 	Extractor     string  `json:"extractor"`
 	ExtractorKey  string  `json:"extractor_key"`
+
+	Subtitles         map[string][]YtdlpSubtitle `json:"subtitles"`
+	AutomaticCaptions map[string][]YtdlpSubtitle `json:"automatic_captions"`
 }
 
 // TVerダウンローダー
 type TVerDownloader struct {
-	YtdlpPath string
-	OutputDir string
-	Options   []string
+	YtdlpPath    string
+	OutputDir    string
+	WorkDir      string // ダウンロード中のファイルを置くディレクトリ (空なら出力ディレクトリ内の .tver_ytdlp_work)
+	Options      []string
+	Timeout      time.Duration // ダウンロード全体のタイムアウト (0で無制限)
+	StallTimeout time.Duration // 進捗が無いまま経過したら停止とみなす時間 (0で無効)
+
+	MinWorkDirCapacity   uint64        // 作業ディレクトリの最低空き容量(MB) (0で確認しない)
+	MinOutputDirCapacity uint64        // 出力ディレクトリの最低空き容量(MB) (0で確認しない)
+	DiskSpaceWait        time.Duration // 空き容量不足時に回復を待つ時間 (0で即中断)
+
+	FfmpegPath      string
+	ContainerFormat string // 出力するコンテナ形式 (mp4, mkv, ts)
+	EmbedMetatag    bool   // TVer APIの番組情報をコンテナにメタデータとして書き込む
+	WriteNFO        bool   // Kodi/Jellyfin/Plex向けのNFOファイルを作成する
+
+	WriteSubtitles bool   // 字幕を別ファイルとして保存する
+	EmbedSubtitle  bool   // 字幕をコンテナに埋め込む
+	SubtitleFormat string // 字幕の変換形式 (srt または vtt)
+
+	SaveThumbnails bool // サムネイルと番組画像を動画の隣に保存する
+	EmbedThumbnail bool // サムネイルをカバーアートとして埋め込む
+
+	Proxy       *url.URL    // API呼び出しとyt-dlpに使うプロキシ (nilなら直接接続)
+	ForwardedIP string      // X-Forwarded-For等で装う日本のIPアドレス (空なら付与しない)
+	Client      *TVerClient // 番組情報取得用 (nilなら必要時に作成)
+
+	Notifier Notifier // 完了・失敗・新着の通知先 (nilなら通知しない)
+	Catalog  *Catalog // ダウンロード済みの判定と記録に使うカタログ (nilなら記録しない)
+
+	RepublishPolicy      string // エピソードIDが変わって再公開された番組の扱い (skip, redownload, replace)
+	RedownloadNewVersion bool   // ダウンロード済みでも新しいバージョンが公開されていれば再ダウンロードして置き換える
+
+	Context    context.Context   // 中断用 (nilなら中断しない)
+	OnProgress func(line string) // yt-dlpの進捗行ごとに呼ばれる (nilなら呼ばない)
+}
+
+// ダウンロード結果
+type DownloadResult struct {
+	MediaPath string         // 出力ディレクトリに移動した動画のパス
+	Detail    *EpisodeDetail // 番組情報 (取得しなかった・失敗した場合はnil)
 }
 
+// 停止したダウンロードの再試行回数
+const maxStallRetries = 2
+
 // 新しいTVerダウンローダーを作成
 func NewTVerDownloader(outputDir string) *TVerDownloader {
 	return &TVerDownloader{
@@ -49,6 +97,15 @@ func NewTVerDownloader(outputDir string) *TVerDownloader {
 			"-N", "10", // 10並列ダウンロード
 			"--write-info-json", // 情報JSONファイルも出力
 		},
+		StallTimeout:         5 * time.Minute,
+		MinWorkDirCapacity:   1000,
+		MinOutputDirCapacity: 1000,
+		FfmpegPath:           "ffmpeg",
+		ContainerFormat:      "mp4",
+		EmbedMetatag:         true,
+		EmbedSubtitle:        true,
+		SubtitleFormat:       "srt",
+		RepublishPolicy:      republishSkip,
 	}
 }
 
@@ -67,11 +124,12 @@ func (d *TVerDownloader) GetVideoInfo(url string) (*YtdlpVideoInfo, error) {
 	fmt.Printf("動画情報取得開始: %s\n", url)
 
 	// yt-dlpコマンドを構築（情報取得のみ）
-	args := []string{
+	args := append(d.proxyArgs(), d.forwardedIPArgs()...)
+	args = append(args,
 		"--dump-json",
 		"--no-download",
 		url,
-	}
+	)
 
 	cmd := exec.Command(d.YtdlpPath, args...)
 	output, err := cmd.Output()
@@ -90,29 +148,184 @@ func (d *TVerDownloader) GetVideoInfo(url string) (*YtdlpVideoInfo, error) {
 
 // yt-dlpを使って動画をダウンロード
 func (d *TVerDownloader) DownloadVideo(url string) error {
+	_, err := d.DownloadEpisode(url)
+	return err
+}
+
+// yt-dlpを使って動画をダウンロードし、保存先と番組情報を返す
+// (カタログでダウンロード済みなら ErrAlreadyDownloaded を返し、試行はカタログに記録する)
+func (d *TVerDownloader) DownloadEpisode(url string) (*DownloadResult, error) {
+	return d.DownloadEpisodeWithDetail(url, nil)
+}
+
+// 取得済みの番組情報を使ってダウンロード (detailがnilならカタログ使用時に取得する)
+func (d *TVerDownloader) DownloadEpisodeWithDetail(url string, detail *EpisodeDetail) (*DownloadResult, error) {
+	var replacedPath string // ダウンロードに成功したら削除する以前の動画
+	if d.Catalog != nil {
+		episodeID, err := extractEpisodeID(url)
+		if err != nil {
+			return nil, err
+		}
+		downloaded := d.Catalog.IsDownloaded(episodeID)
+		if downloaded && !d.RedownloadNewVersion {
+			return nil, fmt.Errorf("%s: %w", episodeID, ErrAlreadyDownloaded)
+		}
+
+		// エピソードIDの変更は番組名・放送日などで、バージョンの更新はAPIの値で判定するため、先に番組情報を取得する
+		if detail == nil {
+			if detail, err = d.fetchEpisodeDetail(url); err != nil {
+				if detail == nil {
+					log.Printf("番組情報取得エラー (エピソードIDの変更・バージョンの更新は確認しません): %v", err)
+				} else {
+					log.Printf("番組情報取得エラー (番組説明・話数なしで続行します): %v", err)
+				}
+			}
+		}
+
+		switch {
+		case downloaded:
+			if detail == nil {
+				return nil, fmt.Errorf("%s: %w", episodeID, ErrAlreadyDownloaded)
+			}
+			if err := d.Catalog.MarkVersionChecked(episodeID, time.Now()); err != nil {
+				log.Printf("カタログ記録エラー: %v", err)
+			}
+			if !d.hasNewVersion(detail) {
+				return nil, fmt.Errorf("%s: %w", episodeID, ErrAlreadyDownloaded)
+			}
+			// 新しい動画は作業ディレクトリで検証してから移動するため、それまで以前の動画は残る
+			replacedPath = d.Catalog.VideoPath(episodeID)
+		case detail != nil:
+			republished, err := d.checkRepublished(url, detail)
+			if err != nil {
+				return nil, err
+			}
+			if republished != nil && d.RepublishPolicy == republishReplace {
+				replacedPath = d.Catalog.VideoPath(republished.ID)
+			}
+		}
+	}
+
+	startedAt := time.Now()
+	result, err := d.downloadEpisode(url, detail, replacedPath)
+	d.recordDownload(url, startedAt, result, err)
+	if err == nil {
+		removeReplacedVideo(replacedPath, result.MediaPath)
+	}
+	return result, err
+}
+
+// カタログ未使用で番組情報が必要なオプションが有効なら、ダウンロード後に取得する
+// (出力ディレクトリの同名の動画を上書きするのは replacePath と同じ場合のみ)
+func (d *TVerDownloader) downloadEpisode(url string, detail *EpisodeDetail, replacePath string) (*DownloadResult, error) {
 	fmt.Printf("ダウンロード開始: %s\n", url)
 
+	// 作業ディレクトリに書き出し、完成後に出力ディレクトリへ移動する
+	jobDir, err := d.createJobDir()
+	if err != nil {
+		return nil, err
+	}
+	// 出力ディレクトリへの移動に失敗した場合は、検証済みの動画を失わないよう作業ディレクトリを残す
+	keepJobDir := false
+	defer func() {
+		if !keepJobDir {
+			os.RemoveAll(jobDir)
+		}
+	}()
+
+	// 空き容量を確認
+	if err := d.waitForDiskSpace(); err != nil {
+		return nil, err
+	}
+
 	// 出力テンプレートを設定
-	outputTemplate := filepath.Join(d.OutputDir, "%(series)s - %(episode)s - %(uploader)s.%(ext)s")
+	outputTemplate := filepath.Join(jobDir, "%(series)s - %(episode)s - %(uploader)s.%(ext)s")
 
-	// yt-dlpコマンドを構築
-	args := append(d.Options,
+	// yt-dlpコマンドを構築（進捗監視のため進捗を1行ずつ出力させる）
+	args := append([]string{}, d.Options...)
+	args = append(args, d.proxyArgs()...)
+	args = append(args, d.forwardedIPArgs()...)
+	args = append(args, d.containerArgs()...)
+	args = append(args, d.subtitleArgs()...)
+	args = append(args,
+		"--newline",
 		"-o", outputTemplate,
 		url,
 	)
 
-	cmd := exec.Command(d.YtdlpPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
 	start := time.Now()
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("yt-dlpダウンロードエラー: %w", err)
+	if err := d.runYtdlp(args); err != nil {
+		return nil, fmt.Errorf("yt-dlpダウンロードエラー: %w", err)
 	}
 
 	duration := time.Since(start)
 	fmt.Printf("ダウンロード完了 (所要時間: %v)\n", duration)
-	return nil
+
+	mediaPath, err := validateDownload(jobDir)
+	if err != nil {
+		return nil, fmt.Errorf("ダウンロード検証エラー: %w", err)
+	}
+	if mediaPath, err = d.remuxIfNeeded(mediaPath); err != nil {
+		return nil, fmt.Errorf("ダウンロード後処理エラー: %w", err)
+	}
+
+	// 番組情報の付与に失敗しても動画自体は保存する (カタログ使用時はダウンロード前に取得を試みている)
+	if detail == nil && d.Catalog == nil && (d.EmbedMetatag || d.WriteNFO || d.SaveThumbnails || d.EmbedThumbnail) {
+		if detail, err = d.fetchEpisodeDetail(url); err != nil {
+			if detail == nil {
+				log.Printf("番組情報取得エラー (番組情報なしで保存します): %v", err)
+			} else {
+				log.Printf("番組情報取得エラー (番組説明・話数なしで保存します): %v", err)
+			}
+		}
+	}
+	if detail != nil && d.Catalog != nil {
+		if err := d.Catalog.UpsertEpisodeDetail(detail); err != nil {
+			log.Printf("カタログ記録エラー: %v", err)
+		}
+	}
+	if detail != nil && d.EmbedMetatag {
+		if err := d.embedMetadata(mediaPath, detail); err != nil {
+			log.Printf("メタデータ書き込みエラー: %v", err)
+		}
+	}
+	if detail != nil && d.WriteNFO {
+		if err := writeEpisodeNFO(mediaPath, detail); err != nil {
+			log.Printf("エピソードNFO作成エラー: %v", err)
+		}
+	}
+	if detail != nil && (d.SaveThumbnails || d.EmbedThumbnail) {
+		if thumbPath, err := d.saveEpisodeThumbnail(mediaPath, detail.ThumbnailURL); err != nil {
+			log.Printf("%v", err)
+		} else {
+			if d.EmbedThumbnail {
+				if err := d.embedThumbnail(mediaPath, thumbPath); err != nil {
+					log.Printf("カバーアート埋め込みエラー: %v", err)
+				}
+			}
+			if !d.SaveThumbnails {
+				os.Remove(thumbPath)
+			}
+		}
+	}
+
+	finalPath, err := d.finalizeDownload(jobDir, mediaPath, replacePath)
+	if err != nil {
+		keepJobDir = true
+		return nil, fmt.Errorf("ダウンロード後処理エラー (ダウンロードした動画は %s に残しています): %w", jobDir, err)
+	}
+
+	if detail != nil && d.WriteNFO {
+		if err := writeTVShowNFO(d.OutputDir, tvshowNFOFromEpisode(detail)); err != nil {
+			log.Printf("番組NFO作成エラー: %v", err)
+		}
+	}
+	if detail != nil && d.SaveThumbnails {
+		if err := d.SaveSeriesPoster(detail.SeriesImageURL); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	return &DownloadResult{MediaPath: finalPath, Detail: detail}, nil
 }
 
 // 動画情報とダウンロードを同時実行
@@ -150,11 +363,20 @@ func (d *TVerDownloader) displayVideoInfo(info *YtdlpVideoInfo) {
 	if info.Duration > 0 {
 		fmt.Printf("長さ: %.0f秒 (%.1f分)\n", info.Duration, info.Duration/60)
 	}
+	if subtitles := describeSubtitles(info.Subtitles); subtitles != "" {
+		fmt.Printf("字幕: %s\n", subtitles)
+	} else {
+		fmt.Println("字幕: なし")
+	}
+	if captions := describeSubtitles(info.AutomaticCaptions); captions != "" {
+		fmt.Printf("自動字幕: %s\n", captions)
+	}
 	fmt.Printf("URL: %s\n", info.Webpage)
 	if info.Description != "" {
 		fmt.Printf("説明: %s\n", strings.TrimSpace(info.Description))
 	}
-	fmt.Println("================\n")
+	fmt.Println("================")
+	fmt.Println()
 }
 
 // 情報をJSONファイルに保存
@@ -200,44 +422,203 @@ func showUsage() {
 	fmt.Println()
 	fmt.Println("コマンド:")
 	fmt.Println("  info     - 動画情報のみ取得")
-	fmt.Println("  download - 動画をダウンロード")
+	fmt.Println("  download - 動画をダウンロード (カタログでダウンロード済みならスキップ)")
 	fmt.Println("  both     - 情報取得とダウンロードの両方")
 	fmt.Println("  series   - シリーズ情報取得・一括ダウンロード")
+	fmt.Println("  series watch <ID,...|リストファイル> - 監視シリーズの新着のみダウンロード")
+	fmt.Println("  discover <sitemap|top|all> - サイトマップ・トップページから未ダウンロードの番組を一括ダウンロード")
+	fmt.Println("  discover mypage/<fav|later|resume|favorite> - マイページの番組を一括ダウンロード")
+	fmt.Println("  serve <待ち受けアドレス> - ダウンロードキューを操作するHTTP/JSON APIを起動")
+	fmt.Println("  catalog list <all|シリーズID> - カタログの番組・エピソードとダウンロード状況を表示")
+	fmt.Println("  catalog import <history.csv|list.csv> - PowerShell版の履歴・番組リストをカタログに取り込む")
+	fmt.Println("  history import <history.csv> - PowerShell版の履歴を壊れた行を除いてカタログに取り込む")
+	fmt.Println("  history export <history.csv> - カタログのダウンロード記録をPowerShell版の履歴形式で書き出す")
+	fmt.Println("  history trim <日数>          - 指定日数より前のダウンロード記録をカタログから削除")
+	fmt.Println("  history search <検索語>      - タイトル・番組名・放送局・エピソードIDで検索")
+	fmt.Println("  history show <エピソードID>  - エピソードの情報とダウンロード試行を表示")
+	fmt.Println("  history stats                - 日別・放送局別・番組別のダウンロード数、容量、失敗率、平均所要時間")
+	fmt.Println("  history forget <エピソードID> - ダウンロード記録を削除して再ダウンロードできるようにする")
 	fmt.Println()
 	fmt.Println("シリーズオプション:")
 	fmt.Println("  --list           - エピソード一覧のみ表示")
-	fmt.Println("  --from N         - N話以降をダウンロード")
-	fmt.Println("  --to N           - N話まででダウンロード")
+	fmt.Println("  --from N         - N話以降をダウンロード (複数シーズンの番組では --season も指定)")
+	fmt.Println("  --to N           - N話まででダウンロード (複数シーズンの番組では --season も指定)")
 	fmt.Println("  --all            - 全話ダウンロード")
+	fmt.Println("  --season S       - シーズンを番号(1〜)、ID、名前で指定")
+	fmt.Println("  --expiring-within D - 配信終了までD以内のエピソードのみ (例: 48h)")
+	fmt.Println()
+	fmt.Println("監視オプション (series watch):")
+	fmt.Println("  --state FILE     - 前回状態の保存先 (既定: 出力ディレクトリ/watch_state.json)")
+	fmt.Println("  --baseline       - ダウンロードせず現在のエピソードを既知として記録")
+	fmt.Println("  --list           - 差分の表示のみ")
+	fmt.Println("  終了コード: 0=新着あり 1=エラー(確認に失敗したシリーズがある場合を含む) 2=新着なし")
+	fmt.Println()
+	fmt.Println("探索オプション (discover):")
+	fmt.Println("  --episode-only   - サイトマップのエピソードページのみ対象 (シリーズ・特集・カテゴリ・タレントを展開しない)")
+	fmt.Println("  見つかったシリーズ・シーズン・特集・カテゴリ・タレントはエピソードに展開")
+	fmt.Println("  --state FILE     - ダウンロード済みの記録先 (既定: 出力ディレクトリ/discover_state.json)")
+	fmt.Println("  --list           - 新着の表示のみ")
+	fmt.Println("  --my-platform-uid UID     - マイページ用のplatform_uid (TVerRec Assistantで確認可能)")
+	fmt.Println("  --my-platform-token TOKEN - マイページ用のplatform_token")
+	fmt.Println("  --my-member-sid SID       - TVer IDでログインしている場合のmember_sid")
+	fmt.Println()
+	fmt.Println("APIサーバーオプション (serve):")
+	fmt.Println("  --workers N      - 同時にダウンロードするジョブ数 (既定1)")
+	fmt.Println("  --state FILE     - 終了したジョブの記録先 (既定: 出力ディレクトリ/serve_history.json)")
+	fmt.Println("  --conf-dir DIR   - 画面から編集するkeyword.conf・ignore.confの置き場所 (既定: ../conf)")
+	fmt.Println("  --sample-dir DIR - 設定ファイルがない場合に表示する雛形の置き場所 (既定: 設定ディレクトリと同じ階層のresources/sample)")
+	fmt.Println("  ホスト名はループバックと待ち受けアドレスに指定したもののみ受け付けます")
+	fmt.Println("  変更を伴う操作 (POST/PUT/DELETE) はブラウザ画面から、または X-Pairing-Token ヘッダー付きのJSONのみ受け付けます")
+	fmt.Println("  GET    /               - キュー・履歴・キーワード・設定を操作するブラウザ画面")
+	fmt.Println("  GET    /api/events     - ジョブ一覧の変更 (Server-Sent Events)")
+	fmt.Println("  GET/PUT /api/lists/{keyword|ignore} - キーワード・除外リストの取得と保存")
+	fmt.Println("  GET/PUT /api/settings  - ダウンロード設定の取得と変更 (再起動で元に戻る)")
+	fmt.Println("  POST   /api/bridge/credentials - TVerRec Assistantからマイページ用の認証情報を受け取る (X-Pairing-Token必須)")
+	fmt.Println("  POST   /api/bridge/enqueue     - TVerRec Assistantで開いている番組を登録 (X-Pairing-Token必須)")
+	fmt.Println("  ペアリングトークン (初回起動時に表示) と受け取った認証情報は <conf-dir>/bridge.json に保存されます")
+	fmt.Println("  POST   /api/jobs       {\"url\": エピソード/シリーズURL, \"season\", \"from\", \"to\"} - ジョブを登録")
+	fmt.Println("  GET    /api/jobs       - ジョブ一覧 (?status=queued|running|completed|failed|canceled|skipped)")
+	fmt.Println("  GET    /api/jobs/{id}  - ジョブの進捗と結果")
+	fmt.Println("  DELETE /api/jobs/{id}  - ジョブの取り消し・中断")
+	fmt.Println("  GET    /api/history    - 終了したジョブの記録 (?q=検索語&status=&limit=)")
+	fmt.Println()
+	fmt.Println("カタログオプション (番組・エピソード・ダウンロード試行の記録、ダウンロード済みの判定に使用):")
+	fmt.Println("  --catalog FILE   - カタログ(SQLite)の保存先 (既定: 出力ディレクトリ/catalog.db)")
+	fmt.Println("  --no-catalog     - カタログを使わない (ダウンロード済みでも再ダウンロードする)")
+	fmt.Println("  既定でカタログを使うため、download・both・series・discover・serve はダウンロード済みのエピソードをスキップします")
+	fmt.Println("  (1話だけ再ダウンロードするには history forget <エピソードID> で記録を削除してください)")
+	fmt.Println("  --on-episode-id-change POLICY - 同じ番組・タイトル・放送日のダウンロード済みエピソードが別IDで再公開された場合")
+	fmt.Println("                     skip: ダウンロードしない (既定), redownload: ダウンロードして以前の動画も残す,")
+	fmt.Println("                     replace: ダウンロードに成功したら以前の動画を削除")
+	fmt.Println("  --redownload-new-version - ダウンロード済みでも新しいバージョン(字幕追加・差し替えなど)が公開されていれば")
+	fmt.Println("                     再ダウンロードし、検証に成功してから以前の動画と置き換える")
+	fmt.Println("                     (discover では配信終了前で、前回の確認から24時間以上経ったものだけ確認する)")
+	fmt.Println()
+	fmt.Println("履歴オプション (history import/export/stats/forget):")
+	fmt.Println("  --retention-days N - ダウンロードから N 日以内の行のみ対象にする")
+	fmt.Println("  --discover-state FILE - forget 時に合わせて外す discover の記録 (既定: 出力ディレクトリ/discover_state.json)")
+	fmt.Println("  --watch-state FILE    - forget 時に合わせて外す series watch の前回状態 (既定: 出力ディレクトリ/watch_state.json)")
+	fmt.Println("  --latest           - エピソードごとに最新の行のみ残し、最新が検証失敗のものは除く")
+	fmt.Println()
+	fmt.Println("ダウンロードオプション:")
+	fmt.Println("  --work-dir DIR    - ダウンロード中のファイルを置くディレクトリ (既定: 出力ディレクトリ/.tver_ytdlp_work)")
+	fmt.Println("  --proxy URL       - API呼び出しとyt-dlpに使うプロキシ (http://, https://, socks5://)")
+	fmt.Println("  --proxy-user USER / --proxy-password PASS - プロキシの認証情報")
+	fmt.Println("  --random-ip       - 日本のIPアドレスをX-Forwarded-For等のヘッダーで装う (プロキシ未使用時のみ)")
+	fmt.Println("  --geoip-list CSV  - 日本のIPアドレス範囲リスト (既定: ../resources/geoip/jp.csv)")
+	fmt.Println("  --timeout N       - N秒で強制終了 (0で無制限)")
+	fmt.Println("  --stall-timeout N - N秒間進捗がなければ停止とみなす (0で無効, 既定300)")
+	fmt.Println("  --min-free-work N   - 作業ディレクトリの最低空き容量MB (0で無効, 既定1000)")
+	fmt.Println("  --min-free-output N - 出力ディレクトリの最低空き容量MB (0で無効, 既定1000)")
+	fmt.Println("                      既定で1000MB未満ならダウンロードしません。以前の動作に戻すには両方に0を指定してください")
+	fmt.Println("  --disk-wait N       - 空き容量不足時にN秒まで回復を待つ (既定0で即中断)")
+	fmt.Println("  --format FMT      - 出力コンテナ形式 (mp4, mkv, ts, 既定mp4)")
+	fmt.Println("  --no-metatag      - 番組情報をメタデータとして書き込まない")
+	fmt.Println("  --nfo             - Kodi/Jellyfin/Plex向けのNFOファイルを作成")
+	fmt.Println("  --thumbnails      - サムネイル(<動画名>-thumb.jpg)と番組画像(poster.jpg)を保存")
+	fmt.Println("  --embed-thumbnail - サムネイルをカバーアートとして埋め込む")
+	fmt.Println("  --write-subs      - 字幕を別ファイルとして保存")
+	fmt.Println("  --no-embed-subs   - 字幕を動画に埋め込まない")
+	fmt.Println("  --sub-format FMT  - 字幕の変換形式 (srt または vtt, 既定srt)")
+	fmt.Println()
+	fmt.Println("通知オプション (シリーズ・探索の完了、個別の失敗、監視シリーズの新着を通知):")
+	fmt.Println("  --notify-desktop    - デスクトップ通知 (D-Bus, notify-send, macOSはosascript)")
+	fmt.Println("  --notify-webhook URL - 通知内容をJSONでPOST (複数指定可)")
+	fmt.Println("  --notify-chat URL    - Discord・Slack互換のWebhookに投稿 (複数指定可)")
 	fmt.Println()
 	fmt.Println("例:")
 	fmt.Println("  go run *.go info https://tver.jp/episodes/epuk32qiqy")
 	fmt.Println("  go run *.go series https://tver.jp/series/srrazrs5j2 --list")
 	fmt.Println("  go run *.go series https://tver.jp/series/srrazrs5j2 --from 10")
 	fmt.Println("  go run *.go series https://tver.jp/series/srrazrs5j2 --from 10 --to 15")
+	fmt.Println("  go run *.go series https://tver.jp/series/srrazrs5j2 --season 2 --from 1 --to 3")
+	fmt.Println("  go run *.go series watch srrazrs5j2,sr1234abcd ./library")
+	fmt.Println("  go run *.go discover sitemap --episode-only --list")
+	fmt.Println("  go run *.go serve 127.0.0.1:8080 --workers 2 ./library")
+	fmt.Println("  go run *.go catalog import ../db/history.csv ./library")
+	fmt.Println("  go run *.go history export ../db/history.csv --retention-days 30 ./library")
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// コマンドを実行して終了コードを返す (終了前にカタログを閉じるため、途中で os.Exit しない)
+func run() int {
 	if len(os.Args) < 3 {
 		showUsage()
-		os.Exit(1)
+		return 1
 	}
 
 	command := os.Args[1]
 	targetURL := os.Args[2]
 
+	// series watch <シリーズID,...|リストファイル> と catalog・history の操作は対象を1つ多く取る (history stats を除く)
+	argStart := 3
+	var watchTarget, operand string
+	if (command == "series" && targetURL == "watch") || command == "catalog" || (command == "history" && targetURL != "stats") {
+		if len(os.Args) < 4 {
+			showUsage()
+			return 1
+		}
+		if command != "series" {
+			operand = os.Args[3]
+		} else {
+			watchTarget = os.Args[3]
+		}
+		argStart = 4
+	}
+
 	// オプション解析
 	var fromEpisode, toEpisode int
-	var listOnly, allEpisodes bool
+	var listOnly, allEpisodes, noMetatag, writeNFO, watchBaseline, episodeOnly bool
+	var writeSubs, noEmbedSubs bool
+	var saveThumbnails, embedThumbnail bool
+	var subFormat, containerFormat, seasonSelector string
+	var workDir, watchState string
+	var forgetDiscoverState, forgetWatchState string
+	var myPlatformUID, myPlatformToken, myMemberSID string
+	var proxyRaw, proxyUser, proxyPassword string
+	var randomIP, notifyDesktop, noCatalog, redownloadNewVersion bool
+	var catalogPath, republishPolicy string
+	var retention time.Duration
+	var latestOnly bool
+	var notifyWebhooks, notifyChatWebhooks []string
+	geoipList := defaultJPIPList
+	var timeoutSec, diskWaitSec int
+	serveWorkers := 1
+	confDir := "../conf"
+	var sampleDir string
+	var expiringWithin time.Duration
+	stallTimeoutSec := -1
+	minFreeWork, minFreeOutput := -1, -1
 	outputDir := "./downloads"
 
-	for i := 3; i < len(os.Args); i++ {
+	for i := argStart; i < len(os.Args); i++ {
 		arg := os.Args[i]
 		switch {
 		case arg == "--list":
 			listOnly = true
 		case arg == "--all":
 			allEpisodes = true
+		case arg == "--no-metatag":
+			noMetatag = true
+		case arg == "--nfo":
+			writeNFO = true
+		case arg == "--thumbnails":
+			saveThumbnails = true
+		case arg == "--embed-thumbnail":
+			embedThumbnail = true
+		case arg == "--write-subs":
+			writeSubs = true
+		case arg == "--no-embed-subs":
+			noEmbedSubs = true
+		case arg == "--format" && i+1 < len(os.Args):
+			containerFormat = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--sub-format" && i+1 < len(os.Args):
+			subFormat = os.Args[i+1]
+			i++ // 次の引数をスキップ
 		case arg == "--from" && i+1 < len(os.Args):
 			if num, err := strconv.Atoi(os.Args[i+1]); err == nil {
 				fromEpisode = num
@@ -248,39 +629,252 @@ func main() {
 				toEpisode = num
 				i++ // 次の引数をスキップ
 			}
+		case arg == "--episode-only":
+			episodeOnly = true
+		case arg == "--notify-desktop":
+			notifyDesktop = true
+		case arg == "--notify-webhook" && i+1 < len(os.Args):
+			notifyWebhooks = append(notifyWebhooks, os.Args[i+1])
+			i++ // 次の引数をスキップ
+		case arg == "--notify-chat" && i+1 < len(os.Args):
+			notifyChatWebhooks = append(notifyChatWebhooks, os.Args[i+1])
+			i++ // 次の引数をスキップ
+		case arg == "--catalog" && i+1 < len(os.Args):
+			catalogPath = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--no-catalog":
+			noCatalog = true
+		case arg == "--redownload-new-version":
+			redownloadNewVersion = true
+		case arg == "--on-episode-id-change" && i+1 < len(os.Args):
+			republishPolicy = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--retention-days" && i+1 < len(os.Args):
+			days, err := parseRetentionDays(os.Args[i+1])
+			if err != nil {
+				log.Printf("オプションエラー: %v", err)
+				return 1
+			}
+			retention = days
+			i++ // 次の引数をスキップ
+		case arg == "--latest":
+			latestOnly = true
+		case arg == "--random-ip":
+			randomIP = true
+		case arg == "--geoip-list" && i+1 < len(os.Args):
+			geoipList = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--proxy" && i+1 < len(os.Args):
+			proxyRaw = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--proxy-user" && i+1 < len(os.Args):
+			proxyUser = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--proxy-password" && i+1 < len(os.Args):
+			proxyPassword = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--my-platform-uid" && i+1 < len(os.Args):
+			myPlatformUID = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--my-platform-token" && i+1 < len(os.Args):
+			myPlatformToken = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--my-member-sid" && i+1 < len(os.Args):
+			myMemberSID = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--baseline":
+			watchBaseline = true
+		case arg == "--state" && i+1 < len(os.Args):
+			watchState = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--discover-state" && i+1 < len(os.Args):
+			forgetDiscoverState = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--watch-state" && i+1 < len(os.Args):
+			forgetWatchState = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--season" && i+1 < len(os.Args):
+			seasonSelector = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--expiring-within" && i+1 < len(os.Args):
+			within, err := time.ParseDuration(os.Args[i+1])
+			if err != nil {
+				log.Printf("--expiring-within の指定が不正です (例: 48h): %v", err)
+				return 1
+			}
+			expiringWithin = within
+			i++ // 次の引数をスキップ
+		case arg == "--workers" && i+1 < len(os.Args):
+			if num, err := strconv.Atoi(os.Args[i+1]); err == nil {
+				serveWorkers = num
+				i++ // 次の引数をスキップ
+			}
+		case arg == "--conf-dir" && i+1 < len(os.Args):
+			confDir = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--sample-dir" && i+1 < len(os.Args):
+			sampleDir = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--timeout" && i+1 < len(os.Args):
+			if num, err := strconv.Atoi(os.Args[i+1]); err == nil {
+				timeoutSec = num
+				i++ // 次の引数をスキップ
+			}
+		case arg == "--stall-timeout" && i+1 < len(os.Args):
+			if num, err := strconv.Atoi(os.Args[i+1]); err == nil {
+				stallTimeoutSec = num
+				i++ // 次の引数をスキップ
+			}
+		case arg == "--work-dir" && i+1 < len(os.Args):
+			workDir = os.Args[i+1]
+			i++ // 次の引数をスキップ
+		case arg == "--min-free-work" && i+1 < len(os.Args):
+			if num, err := strconv.Atoi(os.Args[i+1]); err == nil {
+				minFreeWork = num
+				i++ // 次の引数をスキップ
+			}
+		case arg == "--min-free-output" && i+1 < len(os.Args):
+			if num, err := strconv.Atoi(os.Args[i+1]); err == nil {
+				minFreeOutput = num
+				i++ // 次の引数をスキップ
+			}
+		case arg == "--disk-wait" && i+1 < len(os.Args):
+			if num, err := strconv.Atoi(os.Args[i+1]); err == nil {
+				diskWaitSec = num
+				i++ // 次の引数をスキップ
+			}
 		case !strings.HasPrefix(arg, "--"):
 			outputDir = arg
 		}
 	}
 
-	// yt-dlpの存在確認
-	if err := checkYtdlp(); err != nil {
-		log.Fatalf("yt-dlp確認エラー: %v", err)
+	// yt-dlpの存在確認 (カタログ・履歴の操作のみなら不要)
+	if command != "catalog" && command != "history" {
+		if err := checkYtdlp(); err != nil {
+			log.Printf("yt-dlp確認エラー: %v", err)
+			return 1
+		}
 	}
 
 	// 出力ディレクトリを作成
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Fatalf("出力ディレクトリ作成エラー: %v", err)
+		log.Printf("出力ディレクトリ作成エラー: %v", err)
+		return 1
 	}
 
 	fmt.Printf("出力ディレクトリ: %s\n", outputDir)
 	fmt.Println()
 
+	// ダウンローダーを初期化
+	downloader := NewTVerDownloader(outputDir)
+	if workDir != "" {
+		downloader.WorkDir = workDir
+	}
+	downloader.Timeout = time.Duration(timeoutSec) * time.Second
+	if stallTimeoutSec >= 0 {
+		downloader.StallTimeout = time.Duration(stallTimeoutSec) * time.Second
+	}
+	if minFreeWork >= 0 {
+		downloader.MinWorkDirCapacity = uint64(minFreeWork)
+	}
+	if minFreeOutput >= 0 {
+		downloader.MinOutputDirCapacity = uint64(minFreeOutput)
+	}
+	downloader.DiskSpaceWait = time.Duration(diskWaitSec) * time.Second
+	if containerFormat != "" {
+		downloader.ContainerFormat = containerFormat
+	}
+	// 既定で有効な埋め込みは、コンテナが対応していない場合のみ自動で無効にする
+	capability := containerFormats[downloader.ContainerFormat]
+	downloader.EmbedMetatag = !noMetatag && capability.Metadata
+	downloader.WriteNFO = writeNFO
+	downloader.SaveThumbnails = saveThumbnails
+	downloader.EmbedThumbnail = embedThumbnail
+	downloader.WriteSubtitles = writeSubs
+	downloader.EmbedSubtitle = !noEmbedSubs && capability.Subtitles
+	if subFormat != "" {
+		if !subtitleFormats[subFormat] {
+			log.Printf("不明な字幕形式: %s (srt または vtt を指定してください)", subFormat)
+			return 1
+		}
+		downloader.SubtitleFormat = subFormat
+	}
+	if err := downloader.ValidateContainer(); err != nil {
+		log.Printf("オプションエラー: %v", err)
+		return 1
+	}
+	if republishPolicy != "" {
+		if !republishPolicies[republishPolicy] {
+			log.Printf("不明なエピソードID変更時の扱い: %s (skip, redownload, replace のいずれかを指定してください)", republishPolicy)
+			return 1
+		}
+		downloader.RepublishPolicy = republishPolicy
+	}
+	downloader.RedownloadNewVersion = redownloadNewVersion
+	if proxyRaw != "" {
+		proxyURL, err := ParseProxyURL(proxyRaw, proxyUser, proxyPassword)
+		if err != nil {
+			log.Printf("オプションエラー: %v", err)
+			return 1
+		}
+		downloader.Proxy = proxyURL
+		fmt.Printf("プロキシ: %s\n", redactProxyURL(proxyURL))
+	}
+	// プロキシ使用時は実際の接続元が日本になるため偽装しない
+	if randomIP && downloader.Proxy == nil {
+		pool, err := LoadJPIPPool(geoipList)
+		if err != nil {
+			log.Printf("オプションエラー: %v", err)
+			return 1
+		}
+		downloader.ForwardedIP = pool.Random(rand.New(rand.NewSource(time.Now().UnixNano())))
+		fmt.Printf("日本のIPアドレスを装います: %s\n", downloader.ForwardedIP)
+	}
+
+	// 通知先を設定
+	var notifiers MultiNotifier
+	if notifyDesktop {
+		icon, _ := filepath.Abs("../resources/img/TVerRec-Toast.png")
+		notifiers = append(notifiers, DesktopNotifier{Icon: icon})
+	}
+	for _, url := range notifyWebhooks {
+		notifiers = append(notifiers, WebhookNotifier{URL: url})
+	}
+	for _, url := range notifyChatWebhooks {
+		notifiers = append(notifiers, ChatWebhookNotifier{URL: url})
+	}
+	if len(notifiers) > 0 {
+		downloader.Notifier = notifiers
+	}
+
+	// カタログを開く
+	if !noCatalog || command == "catalog" || command == "history" {
+		if catalogPath == "" {
+			catalogPath = filepath.Join(outputDir, "catalog.db")
+		}
+		catalog, err := OpenCatalog(catalogPath)
+		if err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+		defer catalog.Close()
+		downloader.Catalog = catalog
+	}
+
 	// コマンドに応じて処理を実行
 	switch command {
 	case "info":
 		// エピソードIDを抽出
 		episodeID, err := extractEpisodeID(targetURL)
 		if err != nil {
-			log.Fatalf("エピソードID抽出エラー: %v", err)
+			log.Printf("エピソードID抽出エラー: %v", err)
+			return 1
 		}
 		fmt.Printf("エピソードID: %s\n", episodeID)
-
-		// ダウンローダーを初期化
-		downloader := NewTVerDownloader(outputDir)
 		info, err := downloader.GetVideoInfo(targetURL)
 		if err != nil {
-			log.Fatalf("情報取得エラー: %v", err)
+			log.Printf("情報取得エラー: %v", err)
+			return 1
 		}
 		downloader.displayVideoInfo(info)
 		if err := downloader.SaveInfoToFile(info); err != nil {
@@ -291,92 +885,363 @@ func main() {
 		// エピソードIDを抽出
 		episodeID, err := extractEpisodeID(targetURL)
 		if err != nil {
-			log.Fatalf("エピソードID抽出エラー: %v", err)
+			log.Printf("エピソードID抽出エラー: %v", err)
+			return 1
 		}
 		fmt.Printf("エピソードID: %s\n", episodeID)
-
-		// ダウンローダーを初期化
-		downloader := NewTVerDownloader(outputDir)
-		if err := downloader.DownloadVideo(targetURL); err != nil {
-			log.Fatalf("ダウンロードエラー: %v", err)
+		if err := downloader.DownloadVideo(targetURL); errors.Is(err, ErrAlreadyDownloaded) {
+			fmt.Printf("ダウンロード済みのためスキップしました (再ダウンロードするには --no-catalog を指定してください)\n")
+		} else if err != nil {
+			log.Printf("ダウンロードエラー: %v", err)
+			return 1
 		}
 
 	case "both":
 		// エピソードIDを抽出
 		episodeID, err := extractEpisodeID(targetURL)
 		if err != nil {
-			log.Fatalf("エピソードID抽出エラー: %v", err)
+			log.Printf("エピソードID抽出エラー: %v", err)
+			return 1
 		}
 		fmt.Printf("エピソードID: %s\n", episodeID)
-
-		// ダウンローダーを初期化
-		downloader := NewTVerDownloader(outputDir)
 		info, err := downloader.GetInfoAndDownload(targetURL)
-		if err != nil {
-			log.Fatalf("処理エラー: %v", err)
+		if errors.Is(err, ErrAlreadyDownloaded) {
+			fmt.Printf("ダウンロード済みのためスキップしました (再ダウンロードするには --no-catalog を指定してください)\n")
+		} else if err != nil {
+			log.Printf("処理エラー: %v", err)
+			return 1
 		}
 		if err := downloader.SaveInfoToFile(info); err != nil {
 			log.Printf("情報保存エラー: %v", err)
 		}
 
 	case "series":
-		// シリーズマネージャーを初期化
+		// 監視対象シリーズの新着のみダウンロード
+		if watchTarget != "" {
+			seriesIDs, err := ParseWatchTargets(watchTarget)
+			if err != nil {
+				log.Printf("監視対象解析エラー: %v", err)
+				return 1
+			}
+			if watchState == "" {
+				watchState = filepath.Join(outputDir, "watch_state.json")
+			}
+			code, err := RunSeriesWatch(downloader, seriesIDs, WatchOptions{
+				StatePath: watchState,
+				Baseline:  watchBaseline,
+				DryRun:    listOnly,
+			})
+			if err != nil {
+				log.Printf("新着確認エラー: %v", err)
+				return 1
+			}
+			return code
+		}
+
+		// シリーズマネージャーを初期化 (プロキシ設定を共有するためダウンローダーのクライアントを使う)
 		seriesManager := NewSeriesManager()
+		client, err := downloader.tverClient()
+		if err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+		seriesManager.Client = client
 
 		// シリーズ情報を取得
 		seriesInfo, err := seriesManager.GetSeriesInfo(targetURL)
 		if err != nil {
-			log.Fatalf("シリーズ情報取得エラー: %v", err)
+			log.Printf("シリーズ情報取得エラー: %v", err)
+			return 1
 		}
 
 		// エピソードを解析
 		episodes := seriesManager.ParseEpisodes(seriesInfo)
+		if downloader.Catalog != nil {
+			if err := downloader.Catalog.UpsertSeries(seriesInfo, episodes); err != nil {
+				log.Printf("カタログ記録エラー: %v", err)
+			}
+		}
+
+		// シーズン指定 (--from/--to はシーズン内の話数に適用される)
+		if len(seriesInfo.Seasons) > 1 {
+			seriesManager.DisplaySeasons(seriesInfo.Seasons)
+		}
+		if seasonSelector != "" {
+			season, err := seriesManager.SelectSeason(seriesInfo.Seasons, seasonSelector)
+			if err != nil {
+				log.Printf("シーズン選択エラー: %v", err)
+				return 1
+			}
+			fmt.Printf("選択シーズン: %s\n", season.Title)
+			episodes = seriesManager.FilterSeason(episodes, season.ID)
+		}
 
 		// 範囲フィルタリング
 		if !allEpisodes {
+			if err := seriesManager.CheckEpisodeRange(seriesInfo.Seasons, seasonSelector, fromEpisode, toEpisode); err != nil {
+				log.Printf("%v (--season で指定できます)", err)
+				return 1
+			}
 			episodes = seriesManager.FilterEpisodes(episodes, fromEpisode, toEpisode)
 		}
+		if expiringWithin > 0 {
+			episodes = seriesManager.FilterExpiringWithin(episodes, expiringWithin)
+		}
 
 		// エピソード一覧を表示
 		seriesManager.DisplayEpisodes(episodes)
 
 		// シリーズ情報をファイルに保存
 		seriesFile := filepath.Join(outputDir, "series_info.json")
-		if err := seriesManager.SaveSeriesToFile(episodes, seriesFile); err != nil {
+		if err := seriesManager.SaveSeriesToFile(seriesInfo, episodes, seriesFile); err != nil {
 			log.Printf("シリーズ情報保存エラー: %v", err)
 		}
 
+		// 番組NFOはシリーズ情報(番組説明付き)を優先して作成
+		if writeNFO {
+			if err := writeTVShowNFO(outputDir, tvshowNFOFromSeries(seriesInfo)); err != nil {
+				log.Printf("番組NFO作成エラー: %v", err)
+			}
+		}
+
+		// 番組画像を保存
+		if saveThumbnails {
+			if err := downloader.SaveSeriesPoster(seriesInfo.ImageURL); err != nil {
+				log.Printf("%v", err)
+			}
+		}
+
 		// リスト表示のみの場合は終了
 		if listOnly {
 			fmt.Println("エピソード一覧表示完了!")
-			return
+			return 0
 		}
 
 		// ダウンロード実行
 		if len(episodes) == 0 {
 			fmt.Println("ダウンロード対象のエピソードがありません。")
-			return
+			return 0
 		}
 
 		fmt.Printf("\n%d話のダウンロードを開始します...\n", len(episodes))
-		downloader := NewTVerDownloader(outputDir)
 
-		for i, episode := range episodes {
-			fmt.Printf("\n[%d/%d] ダウンロード中: %s\n", i+1, len(episodes), episode.Title)
+		// 配信終了が近いものから順にダウンロードし、停止したエピソードは最後にまとめて再試行
+		queue := seriesManager.SortByExpiry(episodes)
+		var completed, failed, skipped int
+		for attempt := 0; attempt <= maxStallRetries && len(queue) > 0; attempt++ {
+			if attempt > 0 {
+				fmt.Printf("\n停止した%d話を再試行します (%d回目)\n", len(queue), attempt)
+			}
+
+			var stalled []ParsedEpisode
+			for i, episode := range queue {
+				fmt.Printf("\n[%d/%d] ダウンロード中: %s\n", i+1, len(queue), episode.Title)
+
+				if err := downloader.DownloadVideo(episode.URL); err != nil {
+					if errors.Is(err, ErrAlreadyDownloaded) {
+						skipped++
+						fmt.Printf("ダウンロード済みのためスキップ: %s\n", episode.Title)
+						continue
+					}
+					if errors.Is(err, ErrInsufficientDiskSpace) {
+						downloader.notify(notifyDownloadFailed, "ダウンロード中断: "+seriesInfo.Title, err.Error(), episode.URL)
+						log.Printf("ダウンロードを中断しました: %v", err)
+						return 1
+					}
+					log.Printf("エピソード %d のダウンロードエラー: %v", episode.EpisodeNumber, err)
+					if errors.Is(err, ErrDownloadStalled) && attempt < maxStallRetries {
+						stalled = append(stalled, episode)
+						continue
+					}
+					failed++
+					downloader.notify(notifyDownloadFailed, "ダウンロード失敗: "+episode.Title, err.Error(), episode.URL)
+					continue
+				}
+
+				completed++
+				fmt.Printf("完了: %s\n", episode.Title)
+			}
+			queue = stalled
+		}
+		downloader.notify(notifySeriesCompleted, "ダウンロード完了: "+seriesInfo.Title,
+			fmt.Sprintf("完了 %d話 / 失敗 %d話 / ダウンロード済 %d話", completed, failed, skipped), targetURL)
+
+	case "discover":
+		// 探索元: sitemap, top, all
+		sources := []string{targetURL}
+		if targetURL == "all" {
+			sources = []string{"sitemap", "top"}
+		} else if !discoverSources[targetURL] {
+			log.Printf("不明な探索元: %s (sitemap, top, all, mypage/fav, mypage/later, mypage/resume, mypage/favorite のいずれかを指定してください)", targetURL)
+			return 1
+		}
+
+		// マイページ用の認証情報 (指定がなければ serve でブラウザ拡張機能から受け取ったものを使う)
+		if myMemberSID == "" && (myPlatformUID == "" || myPlatformToken == "") {
+			bridge, err := LoadBridgeConfig(filepath.Join(confDir, "bridge.json"))
+			if err != nil {
+				log.Printf("%v", err)
+			} else if bridge.HasUserCredentials() {
+				fmt.Println("TVerRec Assistantから受け取った認証情報を使います")
+				myPlatformUID, myPlatformToken, myMemberSID = bridge.PlatformUID, bridge.PlatformToken, bridge.MemberSID
+			}
+		}
+		client, err := downloader.userTVerClient(myPlatformUID, myPlatformToken, myMemberSID)
+		if err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+		if strings.HasPrefix(targetURL, "mypage/") && !client.HasUserCredentials() {
+			log.Printf("マイページの取得には --my-platform-uid と --my-platform-token、または --my-member-sid を指定してください (serve 中にTVerRec Assistantから送ることもできます)")
+			return 1
+		}
+
+		if watchState == "" {
+			watchState = filepath.Join(outputDir, "discover_state.json")
+		}
+		if err := RunDiscover(downloader, DiscoverOptions{
+			Sources:     sources,
+			EpisodeOnly: episodeOnly,
+			StatePath:   watchState,
+			DryRun:      listOnly,
+		}); err != nil {
+			log.Printf("探索エラー: %v", err)
+			return 1
+		}
 
-			if err := downloader.DownloadVideo(episode.URL); err != nil {
-				log.Printf("エピソード %d のダウンロードエラー: %v", episode.EpisodeNumber, err)
-				continue
+	case "serve":
+		// 待ち受けアドレス (例: 127.0.0.1:8080)
+		if watchState == "" {
+			watchState = filepath.Join(outputDir, "serve_history.json")
+		}
+		if sampleDir == "" {
+			sampleDir = defaultSampleDir(confDir)
+		}
+		if err := RunServer(downloader, ServeOptions{
+			Addr:        targetURL,
+			Workers:     serveWorkers,
+			HistoryPath: watchState,
+			ConfDir:     confDir,
+			SampleDir:   sampleDir,
+
+			BridgeConfigPath: filepath.Join(confDir, "bridge.json"),
+		}); err != nil {
+			log.Printf("%v", err)
+			return 1
+		}
+
+	case "catalog":
+		switch targetURL {
+		case "list":
+			seriesID := operand
+			if seriesID == "all" {
+				seriesID = ""
+			}
+			downloader.Catalog.Display(seriesID)
+		case "import":
+			result, err := downloader.Catalog.ImportFile(operand)
+			if err != nil {
+				log.Printf("取り込みエラー: %v", err)
+				return 1
 			}
+			fmt.Printf("%s 形式として取り込みました: エピソード %d件, ダウンロード記録 %d件 (取り込み済み %d件, 不正な行 %d件)\n",
+				result.Format, result.Episodes, result.Downloads, result.Duplicate, result.Invalid)
+		default:
+			log.Printf("不明なカタログ操作: %s (list または import を指定してください)", targetURL)
+			return 1
+		}
 
-			fmt.Printf("完了: %s\n", episode.Title)
+	case "history":
+		switch targetURL {
+		case "import":
+			history, err := ReadHistoryFile(operand)
+			if err != nil {
+				log.Printf("取り込みエラー: %v", err)
+				return 1
+			}
+			records := history.Records
+			if retention > 0 {
+				records = LimitHistoryRecords(records, retention, time.Now())
+			}
+			if latestOnly {
+				records = LatestHistoryRecords(records)
+			}
+			result, err := downloader.Catalog.ImportHistory(records, filepath.Base(operand))
+			if err != nil {
+				log.Printf("取り込みエラー: %v", err)
+				return 1
+			}
+			fmt.Printf("履歴を取り込みました: %d行中 %d行 (取り込み済み %d行, 壊れていた行 %d行, 対象外 %d行)\n",
+				len(history.Records)+history.Dropped, result.Downloads, result.Duplicate,
+				history.Dropped+result.Invalid, len(history.Records)-len(records))
+		case "export":
+			records := downloader.Catalog.HistoryRecords(outputDir)
+			if retention > 0 {
+				records = LimitHistoryRecords(records, retention, time.Now())
+			}
+			if latestOnly {
+				records = LatestHistoryRecords(records)
+			}
+			if err := WriteHistoryFile(operand, records); err != nil {
+				log.Printf("書き出しエラー: %v", err)
+				return 1
+			}
+			fmt.Printf("履歴を書き出しました: %s (%d行)\n", operand, len(records))
+		case "trim":
+			days, err := parseRetentionDays(operand)
+			if err != nil {
+				log.Printf("%v", err)
+				return 1
+			}
+			removed, err := downloader.Catalog.TrimDownloads(days, time.Now())
+			if err != nil {
+				log.Printf("履歴削除エラー: %v", err)
+				return 1
+			}
+			fmt.Printf("%s日より前のダウンロード記録を%d件削除しました\n", operand, removed)
+		case "search":
+			displayHistoryMatches(operand, downloader.Catalog.Search(operand))
+		case "show":
+			episode, ok := downloader.Catalog.Episode(operand)
+			if !ok {
+				log.Printf("カタログにないエピソードです: %s", operand)
+				return 1
+			}
+			displayHistoryEpisode(episode, downloader.Catalog.EpisodeDownloads(operand))
+		case "stats":
+			var since time.Time
+			if retention > 0 {
+				since = time.Now().Add(-retention)
+			}
+			displayHistoryStats(downloader.Catalog.Stats(since))
+		case "forget":
+			if forgetDiscoverState == "" {
+				forgetDiscoverState = filepath.Join(outputDir, "discover_state.json")
+			}
+			if forgetWatchState == "" {
+				forgetWatchState = filepath.Join(outputDir, "watch_state.json")
+			}
+			removed, err := ForgetEpisode(downloader.Catalog, operand, forgetDiscoverState, forgetWatchState)
+			if err != nil {
+				log.Printf("履歴削除エラー: %v", err)
+				return 1
+			}
+			if removed == 0 {
+				fmt.Printf("%s のダウンロード記録はありません\n", operand)
+			} else {
+				fmt.Printf("%s のダウンロード記録を%d件削除しました (次回の download・discover・series watch で再ダウンロードされます)\n", operand, removed)
+			}
+		default:
+			log.Printf("不明な履歴操作: %s (import, export, trim, search, show, stats, forget のいずれかを指定してください)", targetURL)
+			return 1
 		}
 
 	default:
 		fmt.Printf("不明なコマンド: %s\n", command)
 		showUsage()
-		os.Exit(1)
+		return 1
 	}
 
 	fmt.Println("処理完了!")
+	return 0
 }
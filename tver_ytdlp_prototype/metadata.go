@@ -0,0 +1,135 @@
+// metadata.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ダウンロード用のTVer APIクライアントを取得 (初回のみトークンを取得)
+func (d *TVerDownloader) tverClient() (*TVerClient, error) {
+	if d.Client != nil {
+		return d.Client, nil
+	}
+
+	client := d.newTVerClient()
+	if err := client.GetToken(); err != nil {
+		return nil, fmt.Errorf("トークン取得エラー: %w", err)
+	}
+	d.Client = client
+	return client, nil
+}
+
+// マイページ用の認証情報を設定したTVer APIクライアントを取得
+// ブラウザのセッション (platform_uid/platform_token) があれば通常のAPIもそれで呼び出し、
+// 匿名のトークンは取得しない (member_sid のみの場合は通常のAPI用にトークンを取得する)
+func (d *TVerDownloader) userTVerClient(platformUID, platformToken, memberSID string) (*TVerClient, error) {
+	if platformUID == "" || platformToken == "" {
+		client, err := d.tverClient()
+		if err != nil {
+			return nil, err
+		}
+		client.SetUserCredentials(platformUID, platformToken, memberSID)
+		return client, nil
+	}
+
+	client := d.newTVerClient()
+	client.PlatformUID = platformUID
+	client.PlatformToken = platformToken
+	client.SetUserCredentials(platformUID, platformToken, memberSID)
+	d.Client = client
+	return client, nil
+}
+
+// プロキシ・送信元IPの設定を反映したクライアントを作成
+func (d *TVerDownloader) newTVerClient() *TVerClient {
+	client := NewTVerClient()
+	if d.Proxy != nil {
+		client.SetProxy(d.Proxy)
+	}
+	client.ForwardedIP = d.ForwardedIP
+	return client
+}
+
+// エピソードURLからTVer APIのエピソード詳細を取得
+func (d *TVerDownloader) fetchEpisodeDetail(url string) (*EpisodeDetail, error) {
+	episodeID, err := extractEpisodeID(url)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := d.tverClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.GetEpisodeDetail(episodeID)
+}
+
+// コンテナに書き込むメタデータを組み立て
+func buildMetadata(detail *EpisodeDetail) [][2]string {
+	var tags [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			tags = append(tags, [2]string{key, value})
+		}
+	}
+
+	add("title", detail.Title)
+	add("show", detail.SeriesTitle)
+	add("album", detail.SeriesTitle)
+	add("season", detail.SeasonTitle)
+	if detail.EpisodeNumber > 0 {
+		add("episode_sort", strconv.Itoa(detail.EpisodeNumber))
+		add("track", strconv.Itoa(detail.EpisodeNumber))
+	}
+	add("episode_id", detail.ID)
+	add("network", detail.Broadcaster)
+	add("artist", detail.Broadcaster)
+	if date, ok := detail.BroadcastDate(); ok {
+		add("date", date.Format("2006-01-02"))
+	} else {
+		add("date", detail.BroadcastLabel)
+	}
+	add("description", detail.Description)
+	add("synopsis", detail.Description)
+	add("comment", detail.URL)
+
+	return tags
+}
+
+// ffmpegで再エンコードせずにメタデータを書き込む
+func (d *TVerDownloader) embedMetadata(mediaPath string, detail *EpisodeDetail) error {
+	ext := filepath.Ext(mediaPath)
+	tmpPath := strings.TrimSuffix(mediaPath, ext) + ".meta" + ext
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", mediaPath,
+		"-map", "0", "-c", "copy",
+	}
+	// MP4では独自キー(season, network等)も残す
+	if ext == ".mp4" || ext == ".m4v" {
+		args = append(args, "-movflags", "+use_metadata_tags")
+	}
+	for _, tag := range buildMetadata(detail) {
+		args = append(args, "-metadata", tag[0]+"="+tag[1])
+	}
+	args = append(args, tmpPath)
+
+	if output, err := exec.Command(d.FfmpegPath, args...).CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpegメタデータ書き込みエラー: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	if err := os.Rename(tmpPath, mediaPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ファイル置換エラー: %w", err)
+	}
+
+	fmt.Printf("メタデータを書き込み: %s\n", filepath.Base(mediaPath))
+	return nil
+}
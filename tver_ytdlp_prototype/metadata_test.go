@@ -0,0 +1,44 @@
+// metadata_test.go
+package main
+
+import "testing"
+
+func TestBuildMetadata(t *testing.T) {
+	detail := &EpisodeDetail{
+		ID:             "ep1",
+		Title:          "第1話",
+		SeriesTitle:    "番組",
+		EpisodeNumber:  1,
+		Broadcaster:    "テレビ局",
+		BroadcastLabel: "放送日未定",
+		URL:            "https://tver.jp/episodes/ep1",
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range buildMetadata(detail) {
+		tags[tag[0]] = tag[1]
+	}
+	want := map[string]string{
+		"title":        "第1話",
+		"show":         "番組",
+		"album":        "番組",
+		"episode_sort": "1",
+		"track":        "1",
+		"episode_id":   "ep1",
+		"network":      "テレビ局",
+		"artist":       "テレビ局",
+		"date":         "放送日未定", // 日付として解析できない表記はそのまま
+		"comment":      "https://tver.jp/episodes/ep1",
+	}
+	for key, value := range want {
+		if tags[key] != value {
+			t.Errorf("%s = %q, want %q", key, tags[key], value)
+		}
+	}
+	// 空の項目は書き込まない
+	for _, key := range []string{"season", "description", "synopsis"} {
+		if _, ok := tags[key]; ok {
+			t.Errorf("空の %s が含まれています", key)
+		}
+	}
+}
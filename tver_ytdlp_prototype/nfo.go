@@ -0,0 +1,124 @@
+// nfo.go
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kodi形式のエピソードNFO
+type episodeNFO struct {
+	XMLName   xml.Name  `xml:"episodedetails"`
+	Title     string    `xml:"title"`
+	ShowTitle string    `xml:"showtitle"`
+	Season    int       `xml:"season"`
+	Episode   int       `xml:"episode,omitempty"`
+	Aired     string    `xml:"aired,omitempty"`
+	Plot      string    `xml:"plot,omitempty"`
+	Studio    string    `xml:"studio,omitempty"`
+	UniqueID  nfoUnique `xml:"uniqueid"`
+}
+
+// Kodi形式の番組NFO
+type tvshowNFO struct {
+	XMLName  xml.Name  `xml:"tvshow"`
+	Title    string    `xml:"title"`
+	Plot     string    `xml:"plot,omitempty"`
+	Studio   string    `xml:"studio,omitempty"`
+	UniqueID nfoUnique `xml:"uniqueid"`
+}
+
+// NFOの識別子
+type nfoUnique struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	ID      string `xml:",chardata"`
+}
+
+// エピソード情報から番組NFOを作成
+func tvshowNFOFromEpisode(detail *EpisodeDetail) tvshowNFO {
+	return tvshowNFO{
+		Title:    detail.SeriesTitle,
+		Studio:   detail.Broadcaster,
+		UniqueID: nfoUnique{Type: "tver", Default: true, ID: detail.SeriesID},
+	}
+}
+
+// シリーズ情報から番組NFOを作成
+func tvshowNFOFromSeries(series *SeriesInfo) tvshowNFO {
+	return tvshowNFO{
+		Title:    series.Title,
+		Plot:     series.Description,
+		Studio:   series.Broadcaster,
+		UniqueID: nfoUnique{Type: "tver", Default: true, ID: series.ID},
+	}
+}
+
+// シーズン名からシーズン番号を推定 (不明な場合は1)
+func parseSeasonNumber(seasonTitle string) int {
+	re := regexp.MustCompile(`(?i)(?:シーズン|season|第)\s*(\d+)\s*(?:期|シーズン|シリーズ)?`)
+	if matches := re.FindStringSubmatch(seasonTitle); len(matches) >= 2 {
+		if num, err := strconv.Atoi(matches[1]); err == nil && num > 0 {
+			return num
+		}
+	}
+	return 1
+}
+
+// NFOをXMLとして書き込む (一時ファイル経由で置き換え)
+func writeNFO(path string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("XML生成エラー: %w", err)
+	}
+
+	content := `<?xml version="1.0" encoding="UTF-8" standalone="yes" ?>` + "\n" + string(data) + "\n"
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("NFO書き込みエラー: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("NFO書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// 動画と同じ名前のエピソードNFOを作成
+func writeEpisodeNFO(mediaPath string, detail *EpisodeDetail) error {
+	nfo := episodeNFO{
+		Title:     detail.Title,
+		ShowTitle: detail.SeriesTitle,
+		Season:    parseSeasonNumber(detail.SeasonTitle),
+		Episode:   detail.EpisodeNumber,
+		Plot:      detail.Description,
+		Studio:    detail.Broadcaster,
+		UniqueID:  nfoUnique{Type: "tver", Default: true, ID: detail.ID},
+	}
+	if date, ok := detail.BroadcastDate(); ok {
+		nfo.Aired = date.Format("2006-01-02")
+	}
+
+	nfoPath := strings.TrimSuffix(mediaPath, filepath.Ext(mediaPath)) + ".nfo"
+	return writeNFO(nfoPath, nfo)
+}
+
+// 番組ディレクトリにtvshow.nfoが無ければ作成
+func writeTVShowNFO(dir string, nfo tvshowNFO) error {
+	nfoPath := filepath.Join(dir, "tvshow.nfo")
+	if _, err := os.Stat(nfoPath); err == nil {
+		return nil
+	}
+
+	if err := writeNFO(nfoPath, nfo); err != nil {
+		return err
+	}
+
+	fmt.Printf("番組NFOを保存: %s\n", nfoPath)
+	return nil
+}
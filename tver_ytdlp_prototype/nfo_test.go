@@ -0,0 +1,77 @@
+// nfo_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSeasonNumber(t *testing.T) {
+	cases := map[string]int{
+		"シーズン2":       2,
+		"Season 3":    3,
+		"第4期":         4,
+		"第2シリーズ":      2,
+		"本編":          1,
+		"":            1,
+		"第0期":         1,
+		"SEASON10 前編": 10,
+	}
+	for title, want := range cases {
+		if got := parseSeasonNumber(title); got != want {
+			t.Errorf("parseSeasonNumber(%q) = %d, want %d", title, got, want)
+		}
+	}
+}
+
+func TestWriteEpisodeNFO(t *testing.T) {
+	dir := t.TempDir()
+	mediaPath := filepath.Join(dir, "番組 - 第1話.mp4")
+	detail := &EpisodeDetail{
+		ID:            "ep1",
+		Title:         "第1話 <特別編>",
+		SeriesTitle:   "番組",
+		SeasonTitle:   "シーズン2",
+		EpisodeNumber: 1,
+		Broadcaster:   "テレビ局",
+		Description:   "A&B",
+	}
+	if err := writeEpisodeNFO(mediaPath, detail); err != nil {
+		t.Fatalf("writeEpisodeNFO: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "番組 - 第1話.nfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8" standalone="yes" ?>`,
+		"<title>第1話 &lt;特別編&gt;</title>",
+		"<season>2</season>",
+		"<episode>1</episode>",
+		"<plot>A&amp;B</plot>",
+		`<uniqueid type="tver" default="true">ep1</uniqueid>`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("NFOに %s がありません:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "<aired>") {
+		t.Errorf("放送日が不明なのに aired があります:\n%s", data)
+	}
+}
+
+func TestWriteTVShowNFOKeepsExisting(t *testing.T) {
+	dir := t.TempDir()
+	nfoPath := filepath.Join(dir, "tvshow.nfo")
+	writeTestFile(t, nfoPath, "手動で編集した内容")
+
+	if err := writeTVShowNFO(dir, tvshowNFO{Title: "番組"}); err != nil {
+		t.Fatalf("writeTVShowNFO: %v", err)
+	}
+	if data, _ := os.ReadFile(nfoPath); string(data) != "手動で編集した内容" {
+		t.Errorf("既存の tvshow.nfo が上書きされました: %s", data)
+	}
+}
@@ -0,0 +1,149 @@
+// notify.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 通知のきっかけ
+const (
+	notifySeriesCompleted = "series_completed" // シリーズ・探索の一括ダウンロードが終了した
+	notifyDownloadFailed  = "download_failed"  // 個別のダウンロードに失敗した
+	notifyNewEpisodes     = "new_episodes"     // 監視シリーズに新着エピソードがあった
+)
+
+// 通知をHTTPで送る際のタイムアウト
+const notifyTimeout = 10 * time.Second
+
+// デスクトップ通知に表示するアプリ名
+const notifyAppName = "TVerRec"
+
+// 通知内容
+type Notification struct {
+	Event   string    `json:"event"`
+	Title   string    `json:"title"`
+	Message string    `json:"message"`
+	URL     string    `json:"url,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// 通知の送信先
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// 複数の送信先にまとめて通知 (失敗した送信先があっても残りには送る)
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) Notify(n Notification) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// デスクトップ通知 (freedesktop D-Bus、なければnotify-send、macOSはosascript)
+type DesktopNotifier struct {
+	Icon string // 通知に表示するアイコンのパス (空なら既定)
+}
+
+func (d DesktopNotifier) Notify(n Notification) error {
+	if runtime.GOOS == "darwin" {
+		script := fmt.Sprintf("display notification %q with title %q subtitle %q", n.Message, notifyAppName, n.Title)
+		if err := exec.Command("osascript", "-e", script).Run(); err != nil {
+			return fmt.Errorf("デスクトップ通知エラー: %w", err)
+		}
+		return nil
+	}
+
+	// org.freedesktop.Notifications.Notify(app_name, replaces_id, app_icon, summary, body, actions, hints, expire_timeout)
+	if _, err := exec.LookPath("gdbus"); err == nil {
+		err := exec.Command("gdbus", "call", "--session",
+			"--dest", "org.freedesktop.Notifications",
+			"--object-path", "/org/freedesktop/Notifications",
+			"--method", "org.freedesktop.Notifications.Notify",
+			notifyAppName, "0", d.Icon, n.Title, n.Message, "[]", "{}", "5000",
+		).Run()
+		if err == nil {
+			return nil
+		}
+	}
+
+	args := []string{"-a", notifyAppName, "-t", "5000"}
+	if d.Icon != "" {
+		args = append(args, "-i", d.Icon)
+	}
+	args = append(args, n.Title, n.Message)
+	if err := exec.Command("notify-send", args...).Run(); err != nil {
+		return fmt.Errorf("デスクトップ通知エラー: %w", err)
+	}
+	return nil
+}
+
+// 通知内容をそのままJSONでPOSTするWebhook
+type WebhookNotifier struct {
+	URL string
+}
+
+func (w WebhookNotifier) Notify(n Notification) error {
+	return postNotification(w.URL, n)
+}
+
+// Discord・Slack互換のWebhook (Slackはtext、Discordはcontentを表示する)
+type ChatWebhookNotifier struct {
+	URL string
+}
+
+func (c ChatWebhookNotifier) Notify(n Notification) error {
+	text := fmt.Sprintf("*%s*\n%s", n.Title, n.Message)
+	if n.URL != "" {
+		text += "\n" + n.URL
+	}
+	return postNotification(c.URL, map[string]string{
+		"username": notifyAppName,
+		"text":     text,
+		"content":  strings.ReplaceAll(text, "*", "**"),
+	})
+}
+
+// JSONをPOSTし、2xx以外はエラーにする
+func postNotification(url string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("JSON生成エラー: %w", err)
+	}
+
+	client := &http.Client{Timeout: notifyTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("Webhook通知エラー: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Webhook通知エラー: %s", resp.Status)
+	}
+	return nil
+}
+
+// 設定された送信先に通知 (未設定なら何もしない、失敗してもダウンロードは続ける)
+func (d *TVerDownloader) notify(event, title, message, url string) {
+	if d.Notifier == nil {
+		return
+	}
+	n := Notification{Event: event, Title: title, Message: message, URL: url, Time: time.Now()}
+	if err := d.Notifier.Notify(n); err != nil {
+		log.Printf("通知エラー: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// notify_test.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 受け取った通知を記録するテスト用の送信先
+type recordingNotifier struct {
+	received []Notification
+	err      error
+}
+
+func (r *recordingNotifier) Notify(n Notification) error {
+	r.received = append(r.received, n)
+	return r.err
+}
+
+func TestMultiNotifier(t *testing.T) {
+	failing := &recordingNotifier{err: errors.New("送信失敗")}
+	ok := &recordingNotifier{}
+
+	err := MultiNotifier{failing, ok}.Notify(Notification{Title: "完了"})
+	if err == nil || !strings.Contains(err.Error(), "送信失敗") {
+		t.Errorf("err = %v, want 送信失敗", err)
+	}
+	if len(failing.received) != 1 || len(ok.received) != 1 {
+		t.Error("失敗した送信先の後にも通知されていません")
+	}
+	if err := (MultiNotifier{ok}).Notify(Notification{}); err != nil {
+		t.Errorf("成功時の err = %v", err)
+	}
+}
+
+// 受け取ったJSONを返すWebhookサーバー
+func newTestWebhook(t *testing.T, status int) (string, *map[string]any) {
+	t.Helper()
+	received := make(map[string]any)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("リクエスト = %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("JSON解析エラー: %v", err)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, &received
+}
+
+func TestWebhookNotifiers(t *testing.T) {
+	n := Notification{Event: notifyDownloadFailed, Title: "ダウンロード失敗: 番組", Message: "エラー", URL: "https://tver.jp/episodes/ep1"}
+
+	url, received := newTestWebhook(t, http.StatusNoContent)
+	if err := (WebhookNotifier{URL: url}).Notify(n); err != nil {
+		t.Fatalf("WebhookNotifier: %v", err)
+	}
+	if (*received)["event"] != notifyDownloadFailed || (*received)["url"] != n.URL {
+		t.Errorf("Webhookの内容 = %v", *received)
+	}
+
+	url, received = newTestWebhook(t, http.StatusOK)
+	if err := (ChatWebhookNotifier{URL: url}).Notify(n); err != nil {
+		t.Fatalf("ChatWebhookNotifier: %v", err)
+	}
+	want := map[string]string{
+		"username": notifyAppName,
+		"text":     "*ダウンロード失敗: 番組*\nエラー\nhttps://tver.jp/episodes/ep1",
+		"content":  "**ダウンロード失敗: 番組**\nエラー\nhttps://tver.jp/episodes/ep1",
+	}
+	for key, value := range want {
+		if (*received)[key] != value {
+			t.Errorf("%s = %q, want %q", key, (*received)[key], value)
+		}
+	}
+
+	url, _ = newTestWebhook(t, http.StatusInternalServerError)
+	if err := (WebhookNotifier{URL: url}).Notify(n); err == nil {
+		t.Error("2xx以外の応答がエラーになっていません")
+	}
+}
+
+func TestDownloaderNotify(t *testing.T) {
+	d := NewTVerDownloader(t.TempDir())
+	d.notify(notifyNewEpisodes, "新着", "", "") // 未設定なら何もしない
+
+	recorder := &recordingNotifier{err: errors.New("送信失敗")}
+	d.Notifier = recorder
+	d.notify(notifyNewEpisodes, "新着 1話: 番組", "第1話", "https://tver.jp/series/sr1")
+	if len(recorder.received) != 1 {
+		t.Fatalf("通知数 = %d, want 1", len(recorder.received))
+	}
+	got := recorder.received[0]
+	if got.Event != notifyNewEpisodes || got.Title != "新着 1話: 番組" || got.Time.IsZero() {
+		t.Errorf("通知内容 = %+v", got)
+	}
+}
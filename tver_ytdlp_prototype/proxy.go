@@ -0,0 +1,66 @@
+// proxy.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// 対応するプロキシの種類
+var proxySchemes = map[string]bool{
+	"http":    true,
+	"https":   true,
+	"socks5":  true,
+	"socks5h": true,
+}
+
+// プロキシURLを解析し、認証情報が別に指定されていればURLに含める
+func ParseProxyURL(raw, username, password string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("プロキシURL解析エラー: %w", err)
+	}
+	if !proxySchemes[proxyURL.Scheme] || proxyURL.Host == "" {
+		return nil, fmt.Errorf("プロキシURLが不正です: %s (http://, https://, socks5:// のいずれかで指定してください)", redactProxyURL(proxyURL))
+	}
+
+	if username != "" {
+		proxyURL.User = url.UserPassword(username, password)
+	}
+	return proxyURL, nil
+}
+
+// ログ表示用にパスワードを伏せたプロキシURL
+func redactProxyURL(proxyURL *url.URL) string {
+	return proxyURL.Redacted()
+}
+
+// API呼び出しをプロキシ経由にする
+func (c *TVerClient) SetProxy(proxyURL *url.URL) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	goProxyURL := *proxyURL
+	// Goのsocks5はホスト名をそのままプロキシに渡すため、socks5hと同じ動作になる
+	if goProxyURL.Scheme == "socks5h" {
+		goProxyURL.Scheme = "socks5"
+	}
+	transport.Proxy = http.ProxyURL(&goProxyURL)
+
+	timeout := 60 * time.Second
+	if c.HTTPClient != nil {
+		timeout = c.HTTPClient.Timeout
+	}
+	c.HTTPClient = &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// プロキシ関連のyt-dlp引数を構築
+func (d *TVerDownloader) proxyArgs() []string {
+	if d.Proxy == nil {
+		return nil
+	}
+	return []string{"--proxy", d.Proxy.String()}
+}
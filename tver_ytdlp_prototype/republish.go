@@ -0,0 +1,168 @@
+// republish.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TVerが同じ番組を別のエピソードIDで再公開した場合の扱い
+// (PowerShell版の downloadWhenEpisodeIdChanged に相当)
+const (
+	republishSkip       = "skip"       // ダウンロードしない (PowerShell版の既定)
+	republishRedownload = "redownload" // 新しいIDでダウンロードし、以前の動画も残す
+	republishReplace    = "replace"    // 新しいIDでダウンロードし、成功したら以前の動画を削除
+)
+
+var republishPolicies = map[string]bool{
+	republishSkip:       true,
+	republishRedownload: true,
+	republishReplace:    true,
+}
+
+// 比較用に空白を詰める
+func normalizeRepublishKey(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// 放送日の表記が同じ日を指すか
+//
+// 表記は「3月17日(月)放送分」(API) と「2025年03月17日(月)放送」(PowerShell版の履歴) のように異なるため日付で比べる。
+// 年のない表記は、それぞれ取得・ダウンロードした日時から年を補う (別の年の同じ日付の放送とは一致しない)。
+// どちらも日付として解析できなければ表記のまま比べる。
+func sameBroadcastDate(label string, labelAt time.Time, other string, otherAt time.Time) bool {
+	date, ok := parseBroadcastDate(label, labelAt)
+	otherDate, otherOK := parseBroadcastDate(other, otherAt)
+	if ok && otherOK {
+		return date.Equal(otherDate)
+	}
+	if ok || otherOK {
+		return false
+	}
+	return normalizeRepublishKey(label) == normalizeRepublishKey(other)
+}
+
+// 同じ番組・シーズン・タイトル・放送日で別のエピソードIDのダウンロード済みエピソードを探す
+// (タイトルか放送日が不明なものは誤判定を避けるため探さない)
+func (c *Catalog) FindRepublished(detail *EpisodeDetail) (CatalogEpisode, bool) {
+	return c.findRepublished(detail, time.Now())
+}
+
+// now は detail を取得した日時
+func (c *Catalog) findRepublished(detail *EpisodeDetail, now time.Time) (CatalogEpisode, bool) {
+	title := normalizeRepublishKey(detail.Title)
+	if title == "" || normalizeRepublishKey(detail.BroadcastLabel) == "" {
+		return CatalogEpisode{}, false
+	}
+
+	// 成功した最新のダウンロードがあるものを新しい順に調べる (シリーズIDが分かればそのシリーズに絞る)
+	rows, err := c.db.Query(`SELECT `+qualifyColumns("e", catalogEpisodeColumns)+`, l.finished_at FROM episodes e `+latestDownloadJoin+`
+		WHERE e.id <> ? AND l.status <> ? AND l.validated <> ? AND (? = '' OR e.series_id IN (?, ''))
+		ORDER BY l.finished_at DESC, l.id DESC`,
+		detail.ID, downloadFailed, validationFailed, detail.SeriesID, detail.SeriesID)
+	if err != nil {
+		logCatalogError(err)
+		return CatalogEpisode{}, false
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var finishedAt int64
+		episode, err := scanCatalogEpisode(rows, &finishedAt)
+		if err != nil {
+			logCatalogError(err)
+			return CatalogEpisode{}, false
+		}
+		downloadedAt := parseCatalogTime(finishedAt)
+		if downloadedAt.IsZero() {
+			downloadedAt = now
+		}
+		if normalizeRepublishKey(episode.Title) != title ||
+			!sameBroadcastDate(detail.BroadcastLabel, now, episode.BroadcastDate, downloadedAt) ||
+			normalizeRepublishKey(episode.SeasonTitle) != normalizeRepublishKey(detail.SeasonTitle) {
+			continue
+		}
+		if (episode.SeriesID == "" || detail.SeriesID == "") &&
+			normalizeRepublishKey(episode.SeriesTitle) != normalizeRepublishKey(detail.SeriesTitle) {
+			continue
+		}
+		return episode, true
+	}
+	return CatalogEpisode{}, false
+}
+
+// エピソードの動画ファイルのパス (最後に成功したダウンロード、なければ空)
+func (c *Catalog) VideoPath(episodeID string) string {
+	var path string
+	var finishedAt time.Time
+	for _, download := range c.queryDownloads(`episode_id = ? AND status = ?`, episodeID, downloadSucceeded) {
+		if historySkippedNames[download.VideoName] {
+			continue
+		}
+		if path != "" && download.FinishedAt.Before(finishedAt) {
+			continue
+		}
+		// PowerShell版の videoPath は保存先からの相対パスのため、videoDir と videoName から組み立てる
+		candidate := download.VideoPath
+		if download.Source != "" && download.VideoDir != "" && download.VideoName != "" {
+			candidate = filepath.Join(download.VideoDir, download.VideoName)
+		}
+		if candidate != "" {
+			path, finishedAt = candidate, download.FinishedAt
+		}
+	}
+	return path
+}
+
+// 再公開されたエピソードか確認し、以前のエピソードを返す
+// (skip の場合はダウンロード対象外として記録し ErrAlreadyDownloaded を返す)
+func (d *TVerDownloader) checkRepublished(url string, detail *EpisodeDetail) (*CatalogEpisode, error) {
+	previous, ok := d.Catalog.FindRepublished(detail)
+	if !ok {
+		return nil, nil
+	}
+	fmt.Printf("エピソードIDが変更されています: %s → %s (%s %s)\n", previous.ID, detail.ID, detail.SeriesTitle, detail.Title)
+
+	if d.RepublishPolicy != republishSkip && d.RepublishPolicy != "" {
+		return &previous, nil
+	}
+
+	if err := d.Catalog.UpsertEpisodeDetail(detail); err != nil {
+		log.Printf("カタログ記録エラー: %v", err)
+	}
+	now := time.Now()
+	skipped := CatalogDownload{
+		EpisodeID:  detail.ID,
+		URL:        url,
+		Status:     downloadSkipped,
+		Error:      fmt.Sprintf("エピソードID変更前の %s をダウンロード済み", previous.ID),
+		Validated:  validationOK,
+		StartedAt:  now,
+		FinishedAt: now,
+	}
+	skipped.setDetail(detail)
+	if err := d.Catalog.RecordDownload(skipped); err != nil {
+		log.Printf("カタログ記録エラー: %v", err)
+	}
+	return &previous, fmt.Errorf("%s は %s と同じ番組です: %w", detail.ID, previous.ID, ErrAlreadyDownloaded)
+}
+
+// 置き換えられた以前の動画を削除 (新しい動画と同じパスなら既に上書きされている)
+func removeReplacedVideo(oldPath, newPath string) {
+	if oldPath == "" || samePath(oldPath, newPath) {
+		return
+	}
+
+	if err := os.Remove(oldPath); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("以前の動画の削除エラー: %v", err)
+		}
+		return
+	}
+	fmt.Printf("以前の動画を削除しました: %s\n", oldPath)
+}
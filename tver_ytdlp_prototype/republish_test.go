@@ -0,0 +1,113 @@
+// republish_test.go
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindRepublished(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.Local)
+	downloadedAt := time.Date(2025, 3, 17, 23, 0, 0, 0, time.Local)
+	lastYear := downloadedAt.AddDate(-1, 0, 0)
+
+	detail := &EpisodeDetail{
+		ID:             "new",
+		SeriesID:       "sr1",
+		SeriesTitle:    "番組",
+		SeasonTitle:    "本編",
+		Title:          "特別編",
+		BroadcastLabel: "3月17日(月)放送分",
+	}
+	cases := []struct {
+		name     string
+		episode  CatalogEpisode // ID は old
+		download CatalogDownload
+		want     bool
+	}{
+		{"同じ表記", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: "特別編", BroadcastDate: "3月17日(月)放送分"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt}, true},
+		{"PowerShell版の表記", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: "特別編", BroadcastDate: "2025年03月17日(月)放送"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt, Source: "history.csv"}, true},
+		{"前年の同じ日付の放送", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: "特別編", BroadcastDate: "3月17日(日)放送分"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: lastYear}, false},
+		{"前年の同じ日付の放送 (PowerShell版)", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: "特別編", BroadcastDate: "2024年03月17日(日)放送"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt, Source: "history.csv"}, false},
+		{"放送日が異なる", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: "特別編", BroadcastDate: "3月10日(月)放送分"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt}, false},
+		{"放送日不明", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: "特別編"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt}, false},
+		{"空白の違い", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: " 特別編  ", BroadcastDate: "3月17日(月)放送分"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt}, true},
+		{"タイトルが異なる", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: "第1話", BroadcastDate: "3月17日(月)放送分"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt}, false},
+		{"シーズンが異なる", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "特別編", Title: "特別編", BroadcastDate: "3月17日(月)放送分"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt}, false},
+		{"シリーズが異なる", CatalogEpisode{SeriesID: "sr2", SeasonTitle: "本編", Title: "特別編", BroadcastDate: "3月17日(月)放送分"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt}, false},
+		{"シリーズID不明なら番組名で比べる", CatalogEpisode{SeriesTitle: "番組", SeasonTitle: "本編", Title: "特別編", BroadcastDate: "3月17日(月)放送分"},
+			CatalogDownload{Status: downloadSucceeded, FinishedAt: downloadedAt}, true},
+		{"ダウンロード失敗", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: "特別編", BroadcastDate: "3月17日(月)放送分"},
+			CatalogDownload{Status: downloadFailed, FinishedAt: downloadedAt}, false},
+		{"検証失敗", CatalogEpisode{SeriesID: "sr1", SeasonTitle: "本編", Title: "特別編", BroadcastDate: "3月17日(月)放送分"},
+			CatalogDownload{Status: downloadSucceeded, Validated: validationFailed, FinishedAt: downloadedAt}, false},
+	}
+	for _, tc := range cases {
+		catalog := newTestCatalog(t)
+		tc.episode.ID = "old"
+		tc.download.EpisodeID = "old"
+		err := catalog.update(func(tx catalogTx) error {
+			if err := tx.putEpisode(&tc.episode); err != nil {
+				return err
+			}
+			_, err := tx.insertDownload(tc.download)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		previous, ok := catalog.findRepublished(detail, now)
+		if ok != tc.want {
+			t.Errorf("%s: found = %v, want %v", tc.name, ok, tc.want)
+		}
+		if ok && previous.ID != "old" {
+			t.Errorf("%s: previous = %q", tc.name, previous.ID)
+		}
+	}
+}
+
+func TestFindRepublishedPrefersLatest(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.Local)
+	catalog := newTestCatalog(t)
+	detail := &EpisodeDetail{ID: "new", SeriesID: "sr1", Title: "特別編", BroadcastLabel: "3月17日(月)放送分"}
+
+	err := catalog.update(func(tx catalogTx) error {
+		for i, id := range []string{"old1", "old2"} {
+			if err := tx.putEpisode(&CatalogEpisode{ID: id, SeriesID: "sr1", Title: "特別編", BroadcastDate: "3月17日(月)放送分"}); err != nil {
+				return err
+			}
+			download := CatalogDownload{EpisodeID: id, Status: downloadSucceeded, FinishedAt: now.Add(time.Duration(i-2) * time.Hour)}
+			if _, err := tx.insertDownload(download); err != nil {
+				return err
+			}
+		}
+		// 同じIDのものは再公開ではない
+		if err := tx.putEpisode(&CatalogEpisode{ID: "new", SeriesID: "sr1", Title: "特別編", BroadcastDate: "3月17日(月)放送分"}); err != nil {
+			return err
+		}
+		_, err := tx.insertDownload(CatalogDownload{EpisodeID: "new", Status: downloadSucceeded, FinishedAt: now})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous, ok := catalog.findRepublished(detail, now)
+	if !ok || previous.ID != "old2" {
+		t.Errorf("findRepublished = %q, %v; want old2", previous.ID, ok)
+	}
+	if _, ok := catalog.findRepublished(&EpisodeDetail{ID: "new", SeriesID: "sr1", Title: "特別編"}, now); ok {
+		t.Error("放送日のない番組で再公開と判定されました")
+	}
+}
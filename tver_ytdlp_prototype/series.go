@@ -8,6 +8,8 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // min関数（Go 1.21未満の場合）
@@ -20,20 +22,33 @@ func min(a, b int) int {
 
 // シリーズ情報（yt-dlpから取得）
 type SeriesInfo struct {
-	Type      string         `json:"_type"`
-	Entries   []EpisodeEntry `json:"entries"`
-	Title     string         `json:"title"`
-	ID        string         `json:"id"`
-	Extractor string         `json:"extractor"`
+	Type        string         `json:"_type"`
+	Entries     []EpisodeEntry `json:"entries"`
+	Title       string         `json:"title"`
+	ID          string         `json:"id"`
+	Extractor   string         `json:"extractor"`
+	ImageURL    string         `json:"image_url"`
+	Description string         `json:"description"`
+	Broadcaster string         `json:"broadcaster"`
+	Seasons     []SeasonInfo   `json:"seasons"`
+
+	// エピソードを取得できなかったシーズン (Entries にはそのシーズンのエピソードが含まれない)
+	FailedSeasons []SeasonInfo `json:"failed_seasons,omitempty"`
 }
 
 // エピソード情報
 type EpisodeEntry struct {
-	Type       string `json:"_type"`
-	Title      string `json:"title"`
-	WebpageURL string `json:"webpage_url"`
-	ID         string `json:"id"`
-	Extractor  string `json:"extractor"`
+	Type         string `json:"_type"`
+	Title        string `json:"title"`
+	WebpageURL   string `json:"webpage_url"`
+	ID           string `json:"id"`
+	Extractor    string `json:"extractor"`
+	ThumbnailURL string `json:"thumbnail"`
+	EndAt        int64  `json:"end_at"` // 配信終了日時 (UNIX秒, 0は不明)
+	SeriesTitle  string `json:"series"`
+	Broadcaster  string `json:"uploader"`
+	SeasonID     string `json:"season_id"`
+	SeasonTitle  string `json:"season"`
 }
 
 // 解析済みエピソード情報
@@ -43,11 +58,17 @@ type ParsedEpisode struct {
 	URL           string
 	ID            string
 	OriginalTitle string
+	ThumbnailURL  string
+	EndAt         time.Time // 配信終了日時 (ゼロ値は不明)
+	SeasonIndex   int       // シリーズ内のシーズン順 (1始まり, 0は不明)
+	SeasonID      string
+	SeasonTitle   string
 }
 
 // シリーズ管理
 type SeriesManager struct {
 	YtdlpPath string
+	Client    *TVerClient // nilなら必要時に作成
 }
 
 // 新しいシリーズマネージャーを作成
@@ -67,9 +88,9 @@ func (sm *SeriesManager) GetSeriesInfo(seriesURL string) (*SeriesInfo, error) {
 	}
 	fmt.Printf("シリーズID: %s\n", seriesID)
 
-	client := NewTVerClient()
-	if err := client.GetToken(); err != nil {
-		return nil, fmt.Errorf("トークン取得エラー: %w", err)
+	client, err := sm.tverClient()
+	if err != nil {
+		return nil, err
 	}
 
 	seasons, err := client.GetSeriesSeasons(seriesID)
@@ -79,25 +100,74 @@ func (sm *SeriesManager) GetSeriesInfo(seriesURL string) (*SeriesInfo, error) {
 	fmt.Printf("シーズン数: %d\n", len(seasons))
 
 	var allEpisodes []EpisodeEntry
-	for _, seasonID := range seasons {
-		episodes, err := client.GetSeasonEpisodes(seasonID)
+	var failedSeasons []SeasonInfo
+	for _, season := range seasons {
+		episodes, err := client.GetSeasonEpisodes(season.ID)
 		if err != nil {
-			fmt.Printf("シーズン %s のエピソード取得エラー: %v\n", seasonID, err)
+			fmt.Printf("シーズン %s のエピソード取得エラー: %v\n", season.ID, err)
+			failedSeasons = append(failedSeasons, season)
 			continue
 		}
+		for i := range episodes {
+			episodes[i].SeasonTitle = season.Title
+		}
 		allEpisodes = append(allEpisodes, episodes...)
 	}
 
 	seriesInfo := &SeriesInfo{
-		Type:    "playlist",
-		Entries: allEpisodes,
-		Title:   "TVerシリーズ",
+		Type:      "playlist",
+		Entries:   allEpisodes,
+		Title:     "TVerシリーズ",
+		ID:        seriesID,
+		Extractor: "TVer",
+		ImageURL:  fmt.Sprintf(seriesImageURLFormat, seriesID),
+		Seasons:   seasons,
+
+		FailedSeasons: failedSeasons,
+	}
+
+	// 番組名などが取得できない場合はエピソード側の情報で補う
+	if detail, err := client.GetSeriesDetail(seriesID); err != nil {
+		fmt.Printf("シリーズ詳細取得エラー: %v\n", err)
+	} else {
+		seriesInfo.Title = detail.Title
+		seriesInfo.Description = detail.Description
+		seriesInfo.Broadcaster = detail.Broadcaster
+	}
+	if len(allEpisodes) > 0 {
+		if seriesInfo.Title == "" || seriesInfo.Title == "TVerシリーズ" {
+			seriesInfo.Title = allEpisodes[0].SeriesTitle
+		}
+		if seriesInfo.Broadcaster == "" {
+			seriesInfo.Broadcaster = allEpisodes[0].Broadcaster
+		}
+	}
+	if seriesInfo.Title == "" {
+		seriesInfo.Title = "TVerシリーズ"
 	}
 
+	fmt.Printf("番組名: %s\n", seriesInfo.Title)
+	if seriesInfo.Broadcaster != "" {
+		fmt.Printf("放送局: %s\n", seriesInfo.Broadcaster)
+	}
 	fmt.Printf("エピソード数: %d話\n", len(allEpisodes))
 	return seriesInfo, nil
 }
 
+// TVer APIクライアントを取得 (初回のみトークンを取得)
+func (sm *SeriesManager) tverClient() (*TVerClient, error) {
+	if sm.Client != nil {
+		return sm.Client, nil
+	}
+
+	client := NewTVerClient()
+	if err := client.GetToken(); err != nil {
+		return nil, fmt.Errorf("トークン取得エラー: %w", err)
+	}
+	sm.Client = client
+	return client, nil
+}
+
 // シリーズURLからシリーズIDを抽出
 func (sm *SeriesManager) extractSeriesID(seriesURL string) (string, error) {
 	re := regexp.MustCompile(`series/([a-zA-Z0-9]+)`)
@@ -141,6 +211,11 @@ func extractEpisodeNumber(title string) int {
 func (sm *SeriesManager) ParseEpisodes(seriesInfo *SeriesInfo) []ParsedEpisode {
 	var episodes []ParsedEpisode
 
+	seasonIndex := make(map[string]int)
+	for i, season := range seriesInfo.Seasons {
+		seasonIndex[season.ID] = i + 1
+	}
+
 	for _, entry := range seriesInfo.Entries {
 		episodeNum := extractEpisodeNumber(entry.Title)
 
@@ -158,13 +233,24 @@ func (sm *SeriesManager) ParseEpisodes(seriesInfo *SeriesInfo) []ParsedEpisode {
 			URL:           entry.WebpageURL,
 			ID:            episodeID,
 			OriginalTitle: entry.Title,
+			ThumbnailURL:  entry.ThumbnailURL,
+			SeasonIndex:   seasonIndex[entry.SeasonID],
+			SeasonID:      entry.SeasonID,
+			SeasonTitle:   entry.SeasonTitle,
+		}
+		if entry.EndAt > 0 {
+			episode.EndAt = time.Unix(entry.EndAt, 0)
 		}
 
 		episodes = append(episodes, episode)
 	}
 
-	// エピソード番号で昇順ソート
-	sort.Slice(episodes, func(i, j int) bool {
+	// シーズンごとにまとめ、シーズン内はエピソード番号で昇順ソート
+	// (シーズンが変わると話数が1から振り直される番組があるため)
+	sort.SliceStable(episodes, func(i, j int) bool {
+		if episodes[i].SeasonIndex != episodes[j].SeasonIndex {
+			return episodes[i].SeasonIndex < episodes[j].SeasonIndex
+		}
 		// エピソード番号が0の場合は最後に
 		if episodes[i].EpisodeNumber == 0 && episodes[j].EpisodeNumber != 0 {
 			return false
@@ -202,10 +288,115 @@ func (sm *SeriesManager) FilterEpisodes(episodes []ParsedEpisode, fromEpisode, t
 	return filtered
 }
 
+// 話数の範囲指定を確認 (話数はシーズンごとに振られるため、複数シーズンの番組ではシーズンの指定が必要)
+func (sm *SeriesManager) CheckEpisodeRange(seasons []SeasonInfo, seasonSelector string, fromEpisode, toEpisode int) error {
+	if (fromEpisode > 0 || toEpisode > 0) && seasonSelector == "" && len(seasons) > 1 {
+		return fmt.Errorf("%dシーズンある番組で話数の範囲を指定するには、シーズンも指定してください", len(seasons))
+	}
+	return nil
+}
+
+// シーズンをインデックス(1始まり)、ID、名前(完全一致を優先し、次に部分一致)のいずれかで選択
+func (sm *SeriesManager) SelectSeason(seasons []SeasonInfo, selector string) (*SeasonInfo, error) {
+	if index, err := strconv.Atoi(selector); err == nil {
+		if index < 1 || index > len(seasons) {
+			return nil, fmt.Errorf("シーズン番号が範囲外です: %d (1〜%d)", index, len(seasons))
+		}
+		return &seasons[index-1], nil
+	}
+
+	for i := range seasons {
+		if seasons[i].ID == selector || seasons[i].Title == selector {
+			return &seasons[i], nil
+		}
+	}
+
+	var matched []*SeasonInfo
+	for i := range seasons {
+		if strings.Contains(seasons[i].Title, selector) {
+			matched = append(matched, &seasons[i])
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("シーズンが見つかりません: %s", selector)
+	case 1:
+		return matched[0], nil
+	}
+	return nil, fmt.Errorf("シーズン指定が曖昧です: %s (%d件一致)", selector, len(matched))
+}
+
+// 指定シーズンのエピソードのみ残す
+func (sm *SeriesManager) FilterSeason(episodes []ParsedEpisode, seasonID string) []ParsedEpisode {
+	var filtered []ParsedEpisode
+	for _, ep := range episodes {
+		if ep.SeasonID == seasonID {
+			filtered = append(filtered, ep)
+		}
+	}
+	return filtered
+}
+
+// シーズン一覧を表示
+func (sm *SeriesManager) DisplaySeasons(seasons []SeasonInfo) {
+	fmt.Println("\n=== シーズン一覧 ===")
+	for i, season := range seasons {
+		fmt.Printf("%2d. %s (ID: %s)\n", i+1, season.Title, season.ID)
+	}
+	fmt.Println("==================")
+}
+
+// 配信終了までの残り時間がwithin以内のエピソードのみ残す
+func (sm *SeriesManager) FilterExpiringWithin(episodes []ParsedEpisode, within time.Duration) []ParsedEpisode {
+	var filtered []ParsedEpisode
+	deadline := time.Now().Add(within)
+
+	for _, ep := range episodes {
+		// 配信終了日時が不明な場合はスキップ
+		if ep.EndAt.IsZero() || ep.EndAt.After(deadline) {
+			continue
+		}
+		filtered = append(filtered, ep)
+	}
+
+	return filtered
+}
+
+// 配信終了が近い順に並べたダウンロード順を返す (配信終了日時が不明なものは最後)
+func (sm *SeriesManager) SortByExpiry(episodes []ParsedEpisode) []ParsedEpisode {
+	sorted := append([]ParsedEpisode{}, episodes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].EndAt.IsZero() != sorted[j].EndAt.IsZero() {
+			return !sorted[i].EndAt.IsZero()
+		}
+		return sorted[i].EndAt.Before(sorted[j].EndAt)
+	})
+	return sorted
+}
+
+// 配信終了日時と残り時間を表示用の文字列にする
+func formatEndAt(endAt time.Time) string {
+	if endAt.IsZero() {
+		return "不明"
+	}
+
+	remaining := time.Until(endAt)
+	if remaining <= 0 {
+		return fmt.Sprintf("%s (配信終了)", endAt.Format("2006-01-02 15:04"))
+	}
+	days := int(remaining.Hours()) / 24
+	hours := int(remaining.Hours()) % 24
+	return fmt.Sprintf("%s (あと%d日%d時間)", endAt.Format("2006-01-02 15:04"), days, hours)
+}
+
 // エピソード一覧を表示
 func (sm *SeriesManager) DisplayEpisodes(episodes []ParsedEpisode) {
 	fmt.Println("\n=== エピソード一覧 ===")
 	for i, ep := range episodes {
+		// シーズンが切り替わるところで見出しを表示
+		if ep.SeasonTitle != "" && (i == 0 || episodes[i-1].SeasonID != ep.SeasonID) {
+			fmt.Printf("--- シーズン: %s ---\n", ep.SeasonTitle)
+		}
 		if ep.EpisodeNumber > 0 {
 			fmt.Printf("%2d. 第%d話: %s\n", i+1, ep.EpisodeNumber, ep.Title)
 		} else {
@@ -213,6 +404,7 @@ func (sm *SeriesManager) DisplayEpisodes(episodes []ParsedEpisode) {
 		}
 		fmt.Printf("    ID: %s\n", ep.ID)
 		fmt.Printf("    URL: %s\n", ep.URL)
+		fmt.Printf("    配信終了: %s\n", formatEndAt(ep.EndAt))
 		fmt.Println()
 	}
 	fmt.Printf("合計: %d話\n", len(episodes))
@@ -220,8 +412,17 @@ func (sm *SeriesManager) DisplayEpisodes(episodes []ParsedEpisode) {
 }
 
 // シリーズ情報をJSONファイルに保存
-func (sm *SeriesManager) SaveSeriesToFile(episodes []ParsedEpisode, filename string) error {
+func (sm *SeriesManager) SaveSeriesToFile(seriesInfo *SeriesInfo, episodes []ParsedEpisode, filename string) error {
 	data := map[string]interface{}{
+		"series": map[string]interface{}{
+			"id":          seriesInfo.ID,
+			"title":       seriesInfo.Title,
+			"description": seriesInfo.Description,
+			"broadcaster": seriesInfo.Broadcaster,
+			"image_url":   seriesInfo.ImageURL,
+			"url":         fmt.Sprintf("https://tver.jp/series/%s", seriesInfo.ID),
+			"seasons":     seriesInfo.Seasons,
+		},
 		"episodes": episodes,
 		"count":    len(episodes),
 	}
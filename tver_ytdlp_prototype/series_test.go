@@ -0,0 +1,260 @@
+// series_test.go
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// シリーズ・シーズン・エピソードのAPIを返すテスト用のハンドラー
+// (episodes はシーズンIDごとのcallSeasonEpisodesの応答、空文字ならエラー)
+func seriesAPIHandler(t *testing.T, seasons string, episodes map[string]string, statics string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/callSeriesSeasons/"):
+			w.Write([]byte(seasons))
+		case strings.Contains(r.URL.Path, "/callSeasonEpisodes/"):
+			id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			body := episodes[id]
+			if body == "" {
+				http.Error(w, "error", http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(body))
+		case strings.HasPrefix(r.URL.Path, "/content/series/"):
+			if statics == "" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(statics))
+		default:
+			t.Errorf("想定外のリクエスト: %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}
+}
+
+const testSeasonsResponse = `{"Result": {"Contents": [
+	{"Type": "season", "Content": {"Id": "ss1", "Title": " 本編 "}},
+	{"Type": "season", "Content": {"Id": "ss2", "Title": "特別編"}}]}}`
+
+var testSeasonEpisodes = map[string]string{
+	"ss1": `{"Result": {"Contents": [
+		{"Type": "episode", "Content": {"Id": "ep2", "Title": "第2話", "SeriesTitle": "番組", "BroadcasterName": "テレビ局", "EndAt": 1700000000}},
+		{"Type": "episode", "Content": {"Id": "ep1", "Title": "第1話", "SeriesTitle": "番組", "BroadcasterName": "テレビ局"}}]}}`,
+	"ss2": `{"Result": {"Contents": [
+		{"Type": "episode", "Content": {"Id": "sp1", "Title": "第1話 特別編", "SeriesTitle": "番組"}}]}}`,
+}
+
+func TestFilterExpiringWithin(t *testing.T) {
+	now := time.Now()
+	episodes := []ParsedEpisode{
+		{ID: "soon", EndAt: now.Add(time.Hour)},
+		{ID: "later", EndAt: now.Add(72 * time.Hour)},
+		{ID: "unknown"},
+		{ID: "ended", EndAt: now.Add(-time.Hour)},
+	}
+	got := NewSeriesManager().FilterExpiringWithin(episodes, 48*time.Hour)
+	if ids := episodeIDs(got); ids != "soon,ended" {
+		t.Errorf("FilterExpiringWithin = %s, want soon,ended", ids)
+	}
+}
+
+func TestSortByExpiry(t *testing.T) {
+	now := time.Now()
+	episodes := []ParsedEpisode{
+		{ID: "unknown1"},
+		{ID: "later", EndAt: now.Add(72 * time.Hour)},
+		{ID: "unknown2"},
+		{ID: "soon", EndAt: now.Add(time.Hour)},
+	}
+	got := NewSeriesManager().SortByExpiry(episodes)
+	if ids := episodeIDs(got); ids != "soon,later,unknown1,unknown2" {
+		t.Errorf("SortByExpiry = %s", ids)
+	}
+	// 元の順序は変えない
+	if episodes[0].ID != "unknown1" {
+		t.Error("元のスライスが並べ替えられました")
+	}
+}
+
+func TestFormatEndAt(t *testing.T) {
+	if got := formatEndAt(time.Time{}); got != "不明" {
+		t.Errorf("ゼロ値 = %q, want 不明", got)
+	}
+	if got := formatEndAt(time.Now().Add(-time.Hour)); !strings.HasSuffix(got, "(配信終了)") {
+		t.Errorf("過去 = %q", got)
+	}
+	if got := formatEndAt(time.Now().Add(49*time.Hour + time.Minute)); !strings.HasSuffix(got, "(あと2日1時間)") {
+		t.Errorf("49時間後 = %q", got)
+	}
+}
+
+// エピソードIDをカンマ区切りにする
+func episodeIDs(episodes []ParsedEpisode) string {
+	ids := make([]string, len(episodes))
+	for i, ep := range episodes {
+		ids[i] = ep.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestGetSeriesInfo(t *testing.T) {
+	statics := `{"title": "番組名", "description": "説明 &amp; あらすじ", "broadcastProviderLabel": "放送局"}`
+	sm := NewSeriesManager()
+	sm.Client = newTestTVerClient(t, seriesAPIHandler(t, testSeasonsResponse, testSeasonEpisodes, statics))
+
+	info, err := sm.GetSeriesInfo("https://tver.jp/series/sr1")
+	if err != nil {
+		t.Fatalf("GetSeriesInfo: %v", err)
+	}
+	if info.ID != "sr1" || info.Title != "番組名" || info.Description != "説明 & あらすじ" || info.Broadcaster != "放送局" {
+		t.Errorf("info = %+v", info)
+	}
+	if len(info.Seasons) != 2 || info.Seasons[0].Title != "本編" {
+		t.Errorf("seasons = %+v", info.Seasons)
+	}
+	if len(info.Entries) != 3 || info.Entries[0].SeasonTitle != "本編" || info.Entries[2].SeasonTitle != "特別編" {
+		t.Errorf("entries = %+v", info.Entries)
+	}
+}
+
+func TestGetSeriesInfoWithoutStatics(t *testing.T) {
+	// 番組詳細が取得できなければエピソードの番組名・放送局で補う
+	sm := NewSeriesManager()
+	sm.Client = newTestTVerClient(t, seriesAPIHandler(t, testSeasonsResponse, testSeasonEpisodes, ""))
+
+	info, err := sm.GetSeriesInfo("https://tver.jp/series/sr1")
+	if err != nil {
+		t.Fatalf("GetSeriesInfo: %v", err)
+	}
+	if info.Title != "番組" || info.Broadcaster != "テレビ局" {
+		t.Errorf("info = %+v", info)
+	}
+}
+
+func TestSelectSeason(t *testing.T) {
+	seasons := []SeasonInfo{
+		{ID: "ss1", Title: "シーズン1"},
+		{ID: "ss2", Title: "シーズン2"},
+		{ID: "ss3", Title: "シーズン2 特別編"},
+	}
+	cases := []struct {
+		selector string
+		want     string // 空ならエラー
+	}{
+		{"1", "ss1"},
+		{"3", "ss3"},
+		{"0", ""},
+		{"4", ""},
+		{"ss2", "ss2"},
+		{"シーズン2", "ss2"}, // 完全一致を部分一致より優先
+		{"特別編", "ss3"},
+		{"シーズン", ""}, // 曖昧
+		{"劇場版", ""},
+	}
+	sm := NewSeriesManager()
+	for _, c := range cases {
+		got, err := sm.SelectSeason(seasons, c.selector)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("SelectSeason(%q) = %s, want エラー", c.selector, got.ID)
+			}
+			continue
+		}
+		if err != nil || got.ID != c.want {
+			t.Errorf("SelectSeason(%q) = %v, %v; want %s", c.selector, got, err, c.want)
+		}
+	}
+}
+
+func TestParseEpisodesGroupsSeasons(t *testing.T) {
+	info := &SeriesInfo{
+		Seasons: []SeasonInfo{{ID: "ss1"}, {ID: "ss2"}},
+		Entries: []EpisodeEntry{
+			{Title: "第2話", WebpageURL: "https://tver.jp/episodes/b2", SeasonID: "ss2"},
+			{Title: "予告", WebpageURL: "https://tver.jp/episodes/a0", SeasonID: "ss1"},
+			{Title: "第2話", WebpageURL: "https://tver.jp/episodes/a2", SeasonID: "ss1", EndAt: 1700000000},
+			{Title: "第1話", WebpageURL: "https://tver.jp/episodes/b1", SeasonID: "ss2"},
+			{Title: "#1", WebpageURL: "https://tver.jp/episodes/a1", SeasonID: "ss1"},
+		},
+	}
+	sm := NewSeriesManager()
+	episodes := sm.ParseEpisodes(info)
+	if ids := episodeIDs(episodes); ids != "a1,a2,a0,b1,b2" {
+		t.Errorf("ParseEpisodes = %s, want a1,a2,a0,b1,b2", ids)
+	}
+	if episodes[0].SeasonIndex != 1 || episodes[3].SeasonIndex != 2 {
+		t.Errorf("シーズン順 = %d, %d", episodes[0].SeasonIndex, episodes[3].SeasonIndex)
+	}
+	if !episodes[1].EndAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("EndAt = %v", episodes[1].EndAt)
+	}
+
+	if ids := episodeIDs(sm.FilterSeason(episodes, "ss2")); ids != "b1,b2" {
+		t.Errorf("FilterSeason = %s, want b1,b2", ids)
+	}
+	// --from/--to はシーズン内の話数に適用され、番号のないものは除く
+	if ids := episodeIDs(sm.FilterEpisodes(episodes, 2, 0)); ids != "a2,b2" {
+		t.Errorf("FilterEpisodes = %s, want a2,b2", ids)
+	}
+}
+
+func TestCheckEpisodeRange(t *testing.T) {
+	oneSeason := []SeasonInfo{{ID: "ss1"}}
+	twoSeasons := []SeasonInfo{{ID: "ss1"}, {ID: "ss2"}}
+	cases := []struct {
+		name     string
+		seasons  []SeasonInfo
+		selector string
+		from, to int
+		wantErr  bool
+	}{
+		{"1シーズンの範囲指定", oneSeason, "", 2, 5, false},
+		{"複数シーズンで範囲指定なし", twoSeasons, "", 0, 0, false},
+		{"複数シーズンでシーズン指定あり", twoSeasons, "2", 2, 0, false},
+		{"複数シーズンで--fromのみ", twoSeasons, "", 2, 0, true},
+		{"複数シーズンで--toのみ", twoSeasons, "", 0, 3, true},
+	}
+	sm := NewSeriesManager()
+	for _, c := range cases {
+		err := sm.CheckEpisodeRange(c.seasons, c.selector, c.from, c.to)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestExtractEpisodeNumber(t *testing.T) {
+	cases := map[string]int{
+		"第12話 タイトル":     12,
+		"Episode 3":     3,
+		"#7 タイトル":       7,
+		"特別編":           0,
+		"第1話 Episode 2": 1,
+	}
+	for title, want := range cases {
+		if got := extractEpisodeNumber(title); got != want {
+			t.Errorf("extractEpisodeNumber(%q) = %d, want %d", title, got, want)
+		}
+	}
+}
+
+func TestGetSeriesInfoFailedSeason(t *testing.T) {
+	episodes := map[string]string{"ss1": testSeasonEpisodes["ss1"]}
+	sm := NewSeriesManager()
+	sm.Client = newTestTVerClient(t, seriesAPIHandler(t, testSeasonsResponse, episodes, ""))
+
+	info, err := sm.GetSeriesInfo("https://tver.jp/series/sr1")
+	if err != nil {
+		t.Fatalf("GetSeriesInfo: %v", err)
+	}
+	if len(info.Entries) != 2 {
+		t.Errorf("entries = %d, want 2", len(info.Entries))
+	}
+	if len(info.FailedSeasons) != 1 || info.FailedSeasons[0].ID != "ss2" {
+		t.Errorf("FailedSeasons = %+v, want ss2", info.FailedSeasons)
+	}
+}
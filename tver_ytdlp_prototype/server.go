@@ -0,0 +1,702 @@
+// server.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ジョブの状態
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+	jobCanceled  = "canceled"
+	jobSkipped   = "skipped" // カタログでダウンロード済みだった
+)
+
+// 終了したジョブとして一覧に残す件数 (それより古いものは履歴のみ)
+const maxFinishedJobs = 200
+
+// サーバー停止時に実行中のジョブの終了を待つ時間
+const serverShutdownTimeout = 30 * time.Second
+
+// yt-dlpの進捗行から進捗率を抽出 (例: [download]  42.3% of ~1.23GiB at 5.00MiB/s)
+var ytdlpProgressPattern = regexp.MustCompile(`^\[download\]\s+([0-9.]+)%`)
+
+// serve の動作設定
+type ServeOptions struct {
+	Addr        string // 待ち受けアドレス (例: 127.0.0.1:8080)
+	Workers     int    // 同時にダウンロードするジョブ数
+	HistoryPath string // 終了したジョブの記録先
+	ConfDir     string // keyword.conf・ignore.confの置き場所
+	SampleDir   string // 設定ファイルがない場合に使う雛形の置き場所
+
+	BridgeConfigPath string // ブラウザ拡張機能との連携設定 (空なら連携しない)
+}
+
+// ダウンロードジョブ
+type Job struct {
+	ID          string     `json:"id"`
+	URL         string     `json:"url"`
+	EpisodeID   string     `json:"episode_id"`
+	Title       string     `json:"title,omitempty"`
+	SeriesTitle string     `json:"series_title,omitempty"`
+	Status      string     `json:"status"`
+	Progress    float64    `json:"progress"`              // 進捗率 (0〜100)
+	LastLine    string     `json:"last_line,omitempty"`   // yt-dlpが最後に出力した行
+	Attempts    int        `json:"attempts"`              // 停止による再試行を含む実行回数
+	Error       string     `json:"error,omitempty"`       // 失敗時のエラー
+	MediaPath   string     `json:"media_path,omitempty"`  // 完了時の保存先
+	CreatedAt   time.Time  `json:"created_at"`            // 登録日時
+	StartedAt   *time.Time `json:"started_at,omitempty"`  // 最後に実行を開始した日時
+	FinishedAt  *time.Time `json:"finished_at,omitempty"` // 終了日時
+
+	cancel context.CancelFunc // 実行中のダウンロードを中断する
+}
+
+// 終了したジョブの記録
+type ServeHistory struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// 記録ファイルを読み込み (存在しなければ空の記録を返す)
+func LoadServeHistory(path string) (*ServeHistory, error) {
+	history := &ServeHistory{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("履歴ファイル読み込みエラー: %w", err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("履歴ファイル解析エラー: %w", err)
+	}
+	return history, nil
+}
+
+// 記録ファイルを保存 (一時ファイル経由で置き換え)
+func (h *ServeHistory) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("JSON生成エラー: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("履歴ファイル書き込みエラー: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("履歴ファイル書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// ジョブキューとワーカーを持つダウンロードサーバー
+type DownloadServer struct {
+	downloader *TVerDownloader
+	opts       ServeOptions
+	download   func(d *TVerDownloader, url string) (*DownloadResult, error) // エピソードのダウンロード (テストで差し替える)
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    map[string]*Job
+	order   []string // 登録順のジョブID
+	queue   []string // 実行待ちのジョブID
+	nextID  int
+	history *ServeHistory
+	closed  bool
+
+	subscribers map[chan struct{}]bool // ジョブの変更を待つSSE接続
+	bridge      *BridgeConfig          // ブラウザ拡張機能との連携設定 (nilなら連携しない)
+	paired      bool                   // 起動時にペアリングトークンを発行した
+}
+
+// 新しいダウンロードサーバーを作成
+func NewDownloadServer(downloader *TVerDownloader, opts ServeOptions) (*DownloadServer, error) {
+	history, err := LoadServeHistory(opts.HistoryPath)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	s := &DownloadServer{
+		downloader: downloader,
+		opts:       opts,
+		download:   (*TVerDownloader).DownloadEpisode,
+		jobs:       make(map[string]*Job),
+		history:    history,
+
+		subscribers: make(map[chan struct{}]bool),
+	}
+	s.cond = sync.NewCond(&s.mu)
+
+	if opts.BridgeConfigPath != "" {
+		if s.bridge, s.paired, err = loadOrPairBridgeConfig(opts.BridgeConfigPath); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// HTTPハンドラーを構築
+func (s *DownloadServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/jobs", s.handleEnqueue)
+	mux.HandleFunc("GET /api/jobs", s.handleListJobs)
+	mux.HandleFunc("GET /api/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("DELETE /api/jobs/{id}", s.handleCancelJob)
+	mux.HandleFunc("GET /api/history", s.handleHistory)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	mux.HandleFunc("GET /api/lists/{name}", s.handleGetList)
+	mux.HandleFunc("PUT /api/lists/{name}", s.handlePutList)
+	mux.HandleFunc("GET /api/settings", s.handleGetSettings)
+	mux.HandleFunc("PUT /api/settings", s.handlePutSettings)
+	mux.HandleFunc("POST /api/bridge/credentials", s.handleBridgeCredentials)
+	mux.HandleFunc("POST /api/bridge/enqueue", s.handleBridgeEnqueue)
+	mux.Handle("GET /", webUIHandler())
+	return s.protect(mux)
+}
+
+// 別サイトのページからの操作 (CSRF) とDNSリバインディングを防ぐ
+// 変更を伴うリクエストは、ペアリングトークン付きか、ブラウザ画面からの同一オリジンのJSONのみ受け付ける
+func (s *DownloadServer) protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowedHost(r.Host) {
+			writeJSONError(w, http.StatusForbidden, fmt.Errorf("許可されていないホスト名です: %s", r.Host))
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.validPairingToken(r) && !sameOrigin(r) {
+			writeJSONError(w, http.StatusUnauthorized, errors.New("ペアリングトークンを指定するか、同一オリジンの画面から操作してください"))
+			return
+		}
+		if r.Method != http.MethodDelete {
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+				writeJSONError(w, http.StatusUnsupportedMediaType, errors.New("Content-Type: application/json で送信してください"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 待ち受けているホスト名か (ループバックと、待ち受けアドレスに明示したホストのみ)
+func (s *DownloadServer) allowedHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	listenHost, _, err := net.SplitHostPort(s.opts.Addr)
+	if err != nil || listenHost == "" {
+		return false
+	}
+	if ip := net.ParseIP(listenHost); ip != nil && ip.IsUnspecified() {
+		return false
+	}
+	return strings.EqualFold(host, strings.Trim(listenHost, "[]"))
+}
+
+// ブラウザ画面からの同一オリジンのリクエストか (ブラウザは変更を伴うfetchに必ずOriginを付ける)
+func sameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" {
+		return false
+	}
+	return r.Header.Get("Origin") == "http://"+r.Host
+}
+
+// ワーカーを起動してHTTPサーバーを実行 (SIGINTで停止)
+func RunServer(downloader *TVerDownloader, opts ServeOptions) error {
+	s, err := NewDownloadServer(downloader, opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var workers sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			s.worker()
+		}()
+	}
+
+	httpServer := &http.Server{Addr: s.opts.Addr, Handler: s.Handler()}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+	fmt.Printf("APIサーバー起動: http://%s (ワーカー数: %d)\n", s.opts.Addr, s.opts.Workers)
+	fmt.Printf("ブラウザで http://%s/ を開くとキューと履歴を操作できます\n", s.opts.Addr)
+	// トークンは発行時のみ表示する (以後は所有者のみ読める連携設定で確認してもらう)
+	if s.paired {
+		fmt.Printf("APIのペアリングトークンを発行しました: %s\n", s.bridge.PairingToken)
+		fmt.Printf("TVerRec Assistantやスクリプトからの操作に使います (%s に保存しました)\n", s.opts.BridgeConfigPath)
+	}
+
+	select {
+	case err := <-serveErr:
+		s.Close()
+		workers.Wait()
+		return fmt.Errorf("APIサーバーエラー: %w", err)
+	case <-ctx.Done():
+	}
+
+	fmt.Println("\nAPIサーバーを停止します")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("APIサーバー停止エラー: %v", err)
+	}
+	s.Close()
+	workers.Wait()
+	return nil
+}
+
+// 実行中のジョブを中断し、ワーカーを終了させる
+func (s *DownloadServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for _, job := range s.jobs {
+		if job.cancel != nil {
+			job.cancel()
+		}
+	}
+	s.cond.Broadcast()
+	for ch := range s.subscribers {
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+}
+
+// キューからジョブを取り出して順に実行
+func (s *DownloadServer) worker() {
+	for {
+		job, ctx, ok := s.nextJob()
+		if !ok {
+			return
+		}
+		s.runJob(ctx, job)
+	}
+}
+
+// 次の実行待ちジョブを実行中にして返す (停止時はfalse)
+func (s *DownloadServer) nextJob() (*Job, context.Context, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		if s.closed {
+			return nil, nil, false
+		}
+		for len(s.queue) > 0 {
+			job := s.jobs[s.queue[0]]
+			s.queue = s.queue[1:]
+			if job.Status != jobQueued {
+				continue
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			now := time.Now()
+			job.Status = jobRunning
+			job.Progress = 0
+			job.LastLine = ""
+			job.Attempts++
+			job.StartedAt = &now
+			job.cancel = cancel
+			s.notifyLocked()
+			return job, ctx, true
+		}
+		s.cond.Wait()
+	}
+}
+
+// ジョブを1回実行し、結果を記録
+func (s *DownloadServer) runJob(ctx context.Context, job *Job) {
+	// 設定の変更は次に開始するジョブから反映する
+	s.mu.Lock()
+	url, seriesTitle := job.URL, job.SeriesTitle
+	downloader := *s.downloader
+	s.mu.Unlock()
+	downloader.Context = ctx
+	downloader.OnProgress = func(line string) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		job.LastLine = line
+		if m := ytdlpProgressPattern.FindStringSubmatch(line); m != nil {
+			if percent, err := strconv.ParseFloat(m[1], 64); err == nil {
+				job.Progress = percent
+			}
+		}
+		s.notifyLocked()
+	}
+
+	// シリーズから登録したジョブは番組ごとのディレクトリに保存
+	d := &downloader
+	var result *DownloadResult
+	var err error
+	if seriesTitle != "" {
+		d, err = downloader.ForSeries(seriesTitle)
+	}
+	if err == nil {
+		result, err = s.download(d, url)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.cancel()
+	job.cancel = nil
+
+	switch {
+	case err == nil:
+		job.Status = jobCompleted
+		job.Progress = 100
+		job.MediaPath = result.MediaPath
+		if result.Detail != nil && job.Title == "" {
+			job.Title = result.Detail.Title
+			job.SeriesTitle = result.Detail.SeriesTitle
+		}
+	case errors.Is(err, ErrAlreadyDownloaded):
+		job.Status = jobSkipped
+		job.Error = err.Error()
+	case job.Status == jobCanceled || s.closed:
+		// 中断されたジョブは再試行しない (停止時は次回起動時に再登録してもらう)
+		job.Status = jobCanceled
+		job.Error = err.Error()
+	case errors.Is(err, ErrDownloadStalled) && job.Attempts <= maxStallRetries:
+		// 停止したジョブはキューの最後に戻して再試行
+		log.Printf("ジョブ %s が停止したため再試行します: %v", job.ID, err)
+		job.Status = jobQueued
+		job.Error = err.Error()
+		s.queue = append(s.queue, job.ID)
+		s.cond.Signal()
+		s.notifyLocked()
+		return
+	default:
+		log.Printf("ジョブ %s のダウンロードエラー: %v", job.ID, err)
+		job.Status = jobFailed
+		job.Error = err.Error()
+		// 通知先の応答を待つ間ロックを保持しないよう別のゴルーチンで送る
+		name := strings.TrimSpace(job.SeriesTitle + " " + job.Title)
+		if name == "" {
+			name = job.EpisodeID
+		}
+		go downloader.notify(notifyDownloadFailed, "ダウンロード失敗: "+name, job.Error, job.URL)
+	}
+	s.finishLocked(job)
+}
+
+// 終了したジョブを履歴に記録し、古い終了ジョブを一覧から外す (s.muを保持して呼ぶ)
+func (s *DownloadServer) finishLocked(job *Job) {
+	now := time.Now()
+	job.FinishedAt = &now
+	defer s.notifyLocked()
+
+	s.history.Jobs = append(s.history.Jobs, *job)
+	if err := s.history.Save(s.opts.HistoryPath); err != nil {
+		log.Printf("%v", err)
+	}
+
+	var finished int
+	for i := len(s.order) - 1; i >= 0; i-- {
+		id := s.order[i]
+		if status := s.jobs[id].Status; status == jobQueued || status == jobRunning {
+			continue
+		}
+		finished++
+		if finished > maxFinishedJobs {
+			delete(s.jobs, id)
+			s.order = append(s.order[:i], s.order[i+1:]...)
+		}
+	}
+}
+
+// ジョブの変更をSSE接続に知らせる (s.muを保持して呼ぶ)
+func (s *DownloadServer) notifyLocked() {
+	for ch := range s.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default: // 通知済みで未送信のものがあればまとめる
+		}
+	}
+}
+
+// ジョブを登録
+func (s *DownloadServer) enqueue(url, episodeID, title, seriesTitle string) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	job := &Job{
+		ID:          strconv.Itoa(s.nextID),
+		URL:         url,
+		EpisodeID:   episodeID,
+		Title:       title,
+		SeriesTitle: seriesTitle,
+		Status:      jobQueued,
+		CreatedAt:   time.Now(),
+	}
+	s.jobs[job.ID] = job
+	s.order = append(s.order, job.ID)
+	s.queue = append(s.queue, job.ID)
+	s.cond.Signal()
+	s.notifyLocked()
+	return *job
+}
+
+// 同じエピソードが実行待ち・実行中なら登録済みのジョブを返す
+func (s *DownloadServer) activeJob(episodeID string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range s.order {
+		job := s.jobs[id]
+		if job.EpisodeID == episodeID && (job.Status == jobQueued || job.Status == jobRunning) {
+			return *job, true
+		}
+	}
+	return Job{}, false
+}
+
+// ジョブ登録のリクエスト
+type enqueueRequest struct {
+	URL    string `json:"url"`    // エピソードまたはシリーズのURL
+	Season string `json:"season"` // シリーズのシーズン指定 (番号、ID、名前)
+	From   int    `json:"from"`   // シーズン内のN話以降
+	To     int    `json:"to"`     // シーズン内のN話まで
+}
+
+// POST /api/jobs: エピソードまたはシリーズを登録
+func (s *DownloadServer) handleEnqueue(w http.ResponseWriter, r *http.Request) {
+	var req enqueueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("リクエスト解析エラー: %w", err))
+		return
+	}
+
+	jobs, err := s.enqueueURL(req)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"jobs": jobs})
+}
+
+// エピソードまたはシリーズのURLをジョブとして登録 (実行待ち・実行中のエピソードは登録済みのジョブを返す)
+func (s *DownloadServer) enqueueURL(req enqueueRequest) ([]Job, error) {
+	var jobs []Job
+	switch {
+	case strings.Contains(req.URL, "/episodes/"):
+		episodeID, err := extractEpisodeID(req.URL)
+		if err != nil {
+			return nil, err
+		}
+		if job, ok := s.activeJob(episodeID); ok {
+			return append(jobs, job), nil
+		}
+		jobs = append(jobs, s.enqueue(req.URL, episodeID, "", ""))
+
+	case strings.Contains(req.URL, "/series/"):
+		episodes, seriesTitle, err := s.seriesEpisodes(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, episode := range episodes {
+			if job, ok := s.activeJob(episode.ID); ok {
+				jobs = append(jobs, job)
+				continue
+			}
+			jobs = append(jobs, s.enqueue(episode.URL, episode.ID, episode.Title, seriesTitle))
+		}
+
+	default:
+		return nil, fmt.Errorf("エピソードまたはシリーズのURLを指定してください: %s", req.URL)
+	}
+	return jobs, nil
+}
+
+// ダウンローダーのクライアントを取得し、以降のジョブで共有する
+func (s *DownloadServer) tverClient() (*TVerClient, error) {
+	s.mu.Lock()
+	downloader := *s.downloader
+	s.mu.Unlock()
+
+	// トークン取得中はロックを保持しない
+	client, err := downloader.tverClient()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if s.downloader.Client == nil {
+		s.downloader.Client = client
+		if s.bridge != nil && s.bridge.HasUserCredentials() {
+			client.SetUserCredentials(s.bridge.PlatformUID, s.bridge.PlatformToken, s.bridge.MemberSID)
+		}
+	}
+	client = s.downloader.Client
+	s.mu.Unlock()
+	return client, nil
+}
+
+// シリーズのエピソードを配信終了が近い順に取得
+func (s *DownloadServer) seriesEpisodes(req enqueueRequest) ([]ParsedEpisode, string, error) {
+	client, err := s.tverClient()
+	if err != nil {
+		return nil, "", err
+	}
+	seriesManager := NewSeriesManager()
+	seriesManager.Client = client
+
+	seriesInfo, err := seriesManager.GetSeriesInfo(req.URL)
+	if err != nil {
+		return nil, "", fmt.Errorf("シリーズ情報取得エラー: %w", err)
+	}
+	if err := seriesManager.CheckEpisodeRange(seriesInfo.Seasons, req.Season, req.From, req.To); err != nil {
+		return nil, "", err
+	}
+	episodes := seriesManager.ParseEpisodes(seriesInfo)
+	if s.downloader.Catalog != nil {
+		if err := s.downloader.Catalog.UpsertSeries(seriesInfo, episodes); err != nil {
+			log.Printf("カタログ記録エラー: %v", err)
+		}
+	}
+	if req.Season != "" {
+		season, err := seriesManager.SelectSeason(seriesInfo.Seasons, req.Season)
+		if err != nil {
+			return nil, "", fmt.Errorf("シーズン選択エラー: %w", err)
+		}
+		episodes = seriesManager.FilterSeason(episodes, season.ID)
+	}
+	if req.From > 0 || req.To > 0 {
+		episodes = seriesManager.FilterEpisodes(episodes, req.From, req.To)
+	}
+	return seriesManager.SortByExpiry(episodes), seriesInfo.Title, nil
+}
+
+// GET /api/jobs: ジョブ一覧 (?status= で絞り込み)
+func (s *DownloadServer) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": s.jobSnapshot(r.URL.Query().Get("status"))})
+}
+
+// GET /api/jobs/{id}: ジョブの詳細
+func (s *DownloadServer) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, ok := s.jobs[r.PathValue("id")]
+	var snapshot Job
+	if ok {
+		snapshot = *job
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("ジョブが見つかりません: %s", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// DELETE /api/jobs/{id}: 実行待ちのジョブを取り消し、実行中のジョブを中断
+func (s *DownloadServer) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[r.PathValue("id")]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("ジョブが見つかりません: %s", r.PathValue("id")))
+		return
+	}
+
+	switch job.Status {
+	case jobQueued:
+		job.Status = jobCanceled
+		s.finishLocked(job)
+	case jobRunning:
+		// 結果の記録はダウンロードが終了したワーカーが行う
+		job.Status = jobCanceled
+		job.cancel()
+		s.notifyLocked()
+	default:
+		writeJSONError(w, http.StatusConflict, fmt.Errorf("ジョブは既に終了しています: %s", job.Status))
+		return
+	}
+	writeJSON(w, http.StatusOK, *job)
+}
+
+// GET /api/history: 終了したジョブの記録 (?q= でタイトル・URL、?status= で状態を絞り込み、新しい順)
+func (s *DownloadServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.URL.Query().Get("q"))
+	status := r.URL.Query().Get("status")
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+
+	s.mu.Lock()
+	jobs := []Job{}
+	for i := len(s.history.Jobs) - 1; i >= 0 && len(jobs) < limit; i-- {
+		job := s.history.Jobs[i]
+		if status != "" && job.Status != status {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(job.Title+" "+job.SeriesTitle+" "+job.URL), query) {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": jobs})
+}
+
+// JSONレスポンスを書き出し
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Printf("レスポンス書き込みエラー: %v", err)
+	}
+}
+
+// エラーをJSONで返す
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,342 @@
+// server_test.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// テスト用のダウンロードサーバー (ワーカーは起動しない)
+func newTestDownloadServer(t *testing.T, addr string) *DownloadServer {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := NewDownloadServer(NewTVerDownloader(dir), ServeOptions{
+		Addr:        addr,
+		HistoryPath: filepath.Join(dir, "serve_history.json"),
+		ConfDir:     filepath.Join(dir, "conf"),
+		SampleDir:   filepath.Join(dir, "sample"),
+
+		BridgeConfigPath: filepath.Join(dir, "conf", "bridge.json"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// テスト用のリクエスト (headersは "名前: 値" の形式)
+func serveTestRequest(handler http.Handler, method, host, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Host = host
+	for _, header := range headers {
+		name, value, _ := strings.Cut(header, ": ")
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServerAllowedHost(t *testing.T) {
+	cases := []struct {
+		addr, host string
+		want       bool
+	}{
+		{"127.0.0.1:8080", "127.0.0.1:8080", true},
+		{"127.0.0.1:8080", "localhost:8080", true},
+		{"127.0.0.1:8080", "[::1]:8080", true},
+		{"127.0.0.1:8080", "attacker.example:8080", false},
+		{":8080", "192.168.1.10:8080", false},
+		{"0.0.0.0:8080", "192.168.1.10:8080", false},
+		{"192.168.1.10:8080", "192.168.1.10:8080", true},
+		{"nas.local:8080", "NAS.local:8080", true},
+	}
+	for _, c := range cases {
+		s := newTestDownloadServer(t, c.addr)
+		if got := s.allowedHost(c.host); got != c.want {
+			t.Errorf("allowedHost(%s) on %s = %v, want %v", c.host, c.addr, got, c.want)
+		}
+	}
+}
+
+func TestServerProtect(t *testing.T) {
+	s := newTestDownloadServer(t, "127.0.0.1:8080")
+	handler := s.Handler()
+	const host = "127.0.0.1:8080"
+	const jsonType = "Content-Type: application/json"
+	token := pairingTokenHeader + ": " + s.bridge.PairingToken
+
+	cases := []struct {
+		name               string
+		method, host, path string
+		body               string
+		headers            []string
+		want               int
+	}{
+		{"一覧の取得", "GET", host, "/api/jobs", "", nil, http.StatusOK},
+		{"DNSリバインディング", "GET", "attacker.example:8080", "/api/jobs", "", nil, http.StatusForbidden},
+		{"別サイトからの登録", "POST", host, "/api/jobs", `{"url": "https://tver.jp/"}`,
+			[]string{jsonType, "Origin: https://attacker.example"}, http.StatusUnauthorized},
+		{"別サイトからのフォーム送信", "POST", host, "/api/jobs", "url=https://tver.jp/",
+			[]string{"Content-Type: application/x-www-form-urlencoded", "Sec-Fetch-Site: cross-site"}, http.StatusUnauthorized},
+		{"トークンなしのスクリプト", "POST", host, "/api/jobs", `{"url": "https://tver.jp/"}`,
+			[]string{jsonType}, http.StatusUnauthorized},
+		{"誤ったトークン", "POST", host, "/api/jobs", `{"url": "https://tver.jp/"}`,
+			[]string{jsonType, pairingTokenHeader + ": 0123"}, http.StatusUnauthorized},
+		{"トークン付きのスクリプト", "POST", host, "/api/jobs", `{"url": "https://tver.jp/"}`,
+			[]string{jsonType, "Authorization: Bearer " + s.bridge.PairingToken}, http.StatusBadRequest},
+		{"トークン付きの拡張機能", "POST", host, "/api/bridge/enqueue", `{"url": "https://tver.jp/"}`,
+			[]string{jsonType, token, "Origin: chrome-extension://abcdef"}, http.StatusBadRequest},
+		{"トークンなしの連携API", "POST", host, "/api/bridge/enqueue", `{"url": "https://tver.jp/"}`,
+			[]string{jsonType, "Origin: http://" + host}, http.StatusUnauthorized},
+		{"JSON以外の登録", "POST", host, "/api/jobs", `{"url": "https://tver.jp/"}`,
+			[]string{"Content-Type: text/plain", token}, http.StatusUnsupportedMediaType},
+		{"同一オリジンからの登録", "POST", host, "/api/jobs", `{"url": "https://tver.jp/"}`,
+			[]string{jsonType + "; charset=utf-8", "Origin: http://" + host, "Sec-Fetch-Site: same-origin"}, http.StatusBadRequest},
+		{"別サイトからの取り消し", "DELETE", host, "/api/jobs/1", "",
+			[]string{"Origin: https://attacker.example"}, http.StatusUnauthorized},
+		{"同一オリジンからの取り消し", "DELETE", host, "/api/jobs/1", "",
+			[]string{"Origin: http://" + host}, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		rec := serveTestRequest(handler, c.method, c.host, c.path, c.body, c.headers...)
+		if rec.Code != c.want {
+			t.Errorf("%s: ステータス = %d, want %d (%s)", c.name, rec.Code, c.want, rec.Body)
+		}
+	}
+}
+
+func TestLoadOrPairBridgeConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf", "bridge.json")
+	config, issued, err := loadOrPairBridgeConfig(path)
+	if err != nil || !issued || len(config.PairingToken) != 32 {
+		t.Fatalf("初回: %+v, %v, %v", config, issued, err)
+	}
+
+	// 2回目以降は保存済みのトークンを使い、発行しない
+	again, issued, err := loadOrPairBridgeConfig(path)
+	if err != nil || issued || again.PairingToken != config.PairingToken {
+		t.Errorf("2回目: %+v, %v, %v", again, issued, err)
+	}
+}
+
+func TestBridgeCredentialsReplaceClient(t *testing.T) {
+	s := newTestDownloadServer(t, "127.0.0.1:8080")
+	running := NewTVerClient()
+	running.PlatformUID = "anonymous"
+	s.downloader.Client = running
+
+	rec := serveTestRequest(s.Handler(), "POST", "127.0.0.1:8080", "/api/bridge/credentials", `{"member_sid": "sid1"}`,
+		"Content-Type: application/json", pairingTokenHeader+": "+s.bridge.PairingToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ステータス = %d (%s)", rec.Code, rec.Body)
+	}
+
+	// 実行中のジョブが使っているクライアントは変更せず、次のジョブから新しい認証情報を使う
+	if running.HasUserCredentials() {
+		t.Error("実行中のジョブのクライアントが変更されました")
+	}
+	client := s.downloader.Client
+	if client == running || client.MemberSID != "sid1" || client.PlatformUID != "anonymous" {
+		t.Errorf("client = %+v", client)
+	}
+}
+
+// テスト用のワーカーを起動 (終了時にサーバーを停止してワーカーの終了を待つ)
+func startTestWorker(t *testing.T, s *DownloadServer) {
+	t.Helper()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.worker()
+	}()
+	t.Cleanup(func() {
+		s.Close()
+		wg.Wait()
+	})
+}
+
+// ジョブが指定の状態で終了するまで待つ (実行中に取り消したジョブはワーカーが記録するまで待つ)
+func waitTestJob(t *testing.T, s *DownloadServer, id, status string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		job := *s.jobs[id]
+		s.mu.Unlock()
+		if job.Status == status && job.FinishedAt != nil {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("ジョブ %s の状態 = %s, want %s (%s)", id, job.Status, status, job.Error)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// APIからエピソードを登録し、ジョブIDを返す
+func enqueueTestEpisode(t *testing.T, s *DownloadServer, episodeID string) string {
+	t.Helper()
+	rec := serveTestRequest(s.Handler(), "POST", "127.0.0.1:8080", "/api/jobs", `{"url": "https://tver.jp/episodes/`+episodeID+`"}`,
+		"Content-Type: application/json", pairingTokenHeader+": "+s.bridge.PairingToken)
+	var resp struct {
+		Jobs []Job `json:"jobs"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); rec.Code != http.StatusCreated || err != nil || len(resp.Jobs) != 1 {
+		t.Fatalf("登録: ステータス = %d (%s)", rec.Code, rec.Body)
+	}
+	return resp.Jobs[0].ID
+}
+
+func TestServerJobLifecycle(t *testing.T) {
+	cases := []struct {
+		name   string
+		result *DownloadResult
+		err    error
+		want   string
+	}{
+		{"成功", &DownloadResult{MediaPath: "/out/ep1.mp4", Detail: &EpisodeDetail{Title: "第1話", SeriesTitle: "番組"}}, nil, jobCompleted},
+		{"ダウンロード済み", nil, fmt.Errorf("ep1: %w", ErrAlreadyDownloaded), jobSkipped},
+		{"失敗", nil, errors.New("yt-dlpエラー"), jobFailed},
+	}
+	for _, c := range cases {
+		s := newTestDownloadServer(t, "127.0.0.1:8080")
+		var gotURL string
+		s.download = func(d *TVerDownloader, url string) (*DownloadResult, error) {
+			gotURL = url
+			return c.result, c.err
+		}
+		startTestWorker(t, s)
+
+		job := waitTestJob(t, s, enqueueTestEpisode(t, s, "ep1"), c.want)
+		if gotURL != "https://tver.jp/episodes/ep1" || job.Attempts != 1 || job.FinishedAt == nil {
+			t.Errorf("%s: url = %s, job = %+v", c.name, gotURL, job)
+		}
+		if c.result != nil && (job.MediaPath != c.result.MediaPath || job.Title != "第1話" || job.Progress != 100) {
+			t.Errorf("%s: job = %+v", c.name, job)
+		}
+		if c.err != nil && job.Error != c.err.Error() {
+			t.Errorf("%s: Error = %q", c.name, job.Error)
+		}
+
+		s.mu.Lock()
+		history := s.history.Jobs
+		s.mu.Unlock()
+		if len(history) != 1 || history[0].ID != job.ID || history[0].Status != c.want {
+			t.Errorf("%s: 履歴 = %+v", c.name, history)
+		}
+	}
+}
+
+func TestServerCancelJob(t *testing.T) {
+	const host = "127.0.0.1:8080"
+	origin := "Origin: http://" + host
+
+	// 実行待ちのジョブ (ワーカーを起動しない) はその場で取り消して履歴に記録
+	s := newTestDownloadServer(t, host)
+	id := enqueueTestEpisode(t, s, "ep1")
+	if rec := serveTestRequest(s.Handler(), "DELETE", host, "/api/jobs/"+id, "", origin); rec.Code != http.StatusOK {
+		t.Fatalf("実行待ち: ステータス = %d (%s)", rec.Code, rec.Body)
+	}
+	waitTestJob(t, s, id, jobCanceled)
+	if len(s.history.Jobs) != 1 || s.history.Jobs[0].Status != jobCanceled {
+		t.Errorf("実行待ち: 履歴 = %+v", s.history.Jobs)
+	}
+	if rec := serveTestRequest(s.Handler(), "DELETE", host, "/api/jobs/"+id, "", origin); rec.Code != http.StatusConflict {
+		t.Errorf("終了済み: ステータス = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// 実行中のジョブはダウンロードを中断し、再試行しない
+	s = newTestDownloadServer(t, host)
+	started := make(chan struct{})
+	var calls int
+	s.download = func(d *TVerDownloader, url string) (*DownloadResult, error) {
+		calls++
+		close(started)
+		<-d.Context.Done()
+		return nil, fmt.Errorf("%w: %w", ErrDownloadStalled, d.Context.Err())
+	}
+	startTestWorker(t, s)
+	id = enqueueTestEpisode(t, s, "ep1")
+	<-started
+	if rec := serveTestRequest(s.Handler(), "DELETE", host, "/api/jobs/"+id, "", origin); rec.Code != http.StatusOK {
+		t.Fatalf("実行中: ステータス = %d (%s)", rec.Code, rec.Body)
+	}
+	job := waitTestJob(t, s, id, jobCanceled)
+	s.mu.Lock()
+	history := s.history.Jobs
+	s.mu.Unlock()
+	if calls != 1 || job.FinishedAt == nil || len(history) != 1 || history[0].Status != jobCanceled {
+		t.Errorf("実行中: calls = %d, job = %+v, 履歴 = %+v", calls, job, history)
+	}
+}
+
+func TestServerStallRetry(t *testing.T) {
+	s := newTestDownloadServer(t, "127.0.0.1:8080")
+	var calls int
+	s.download = func(d *TVerDownloader, url string) (*DownloadResult, error) {
+		calls++
+		return nil, ErrDownloadStalled
+	}
+	startTestWorker(t, s)
+
+	// 停止したジョブは maxStallRetries 回まで再試行し、それでも停止したら失敗にする
+	job := waitTestJob(t, s, enqueueTestEpisode(t, s, "ep1"), jobFailed)
+	if job.Attempts != maxStallRetries+1 || calls != maxStallRetries+1 {
+		t.Errorf("Attempts = %d, calls = %d, want %d", job.Attempts, calls, maxStallRetries+1)
+	}
+	if len(s.history.Jobs) != 1 {
+		t.Errorf("履歴 = %+v", s.history.Jobs)
+	}
+}
+
+func TestServerHistoryFilter(t *testing.T) {
+	s := newTestDownloadServer(t, "127.0.0.1:8080")
+	s.history.Jobs = []Job{
+		{ID: "1", URL: "https://tver.jp/episodes/ep1", Title: "第1話", SeriesTitle: "ドラマA", Status: jobCompleted},
+		{ID: "2", URL: "https://tver.jp/episodes/ep2", Title: "第2話", SeriesTitle: "ドラマA", Status: jobFailed},
+		{ID: "3", URL: "https://tver.jp/episodes/ep3", Title: "第1話", SeriesTitle: "バラエティB", Status: jobCompleted},
+		{ID: "4", URL: "https://tver.jp/episodes/ep4", Title: "第2話", SeriesTitle: "バラエティB", Status: jobSkipped},
+	}
+
+	cases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"全件 (新しい順)", "", "4,3,2,1"},
+		{"状態", "?status=completed", "3,1"},
+		{"番組名", "?q=ドラマa", "2,1"},
+		{"URL", "?q=EP3", "3"},
+		{"状態と番組名", "?status=completed&q=バラエティ", "3"},
+		{"件数", "?limit=2", "4,3"},
+		{"不正な件数", "?limit=x", "4,3,2,1"},
+		{"該当なし", "?status=canceled", ""},
+	}
+	for _, c := range cases {
+		rec := serveTestRequest(s.Handler(), "GET", "127.0.0.1:8080", "/api/history"+c.query, "")
+		var resp struct {
+			Jobs []Job `json:"jobs"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("%s: ステータス = %d (%s)", c.name, rec.Code, rec.Body)
+		}
+		var ids []string
+		for _, job := range resp.Jobs {
+			ids = append(ids, job.ID)
+		}
+		if got := strings.Join(ids, ","); got != c.want {
+			t.Errorf("%s: %s, want %s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// subtitle.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// yt-dlpが返す字幕トラック
+type YtdlpSubtitle struct {
+	Ext  string `json:"ext"`
+	URL  string `json:"url"`
+	Name string `json:"name"`
+}
+
+// 変換可能な字幕形式
+var subtitleFormats = map[string]bool{
+	"srt": true,
+	"vtt": true,
+}
+
+// 字幕関連のyt-dlp引数を構築
+func (d *TVerDownloader) subtitleArgs() []string {
+	if !d.WriteSubtitles && !d.EmbedSubtitle {
+		return nil
+	}
+
+	format := d.SubtitleFormat
+	if !subtitleFormats[format] {
+		format = "srt"
+	}
+
+	args := []string{"--sub-langs", "all", "--convert-subs", format}
+	// --write-subsを併用すると埋め込み後も字幕ファイルが残る
+	if d.WriteSubtitles {
+		args = append(args, "--write-subs")
+	}
+	if d.EmbedSubtitle {
+		args = append(args, "--embed-subs")
+	}
+	return args
+}
+
+// 字幕の言語と形式を表示用の文字列にする
+func describeSubtitles(subtitles map[string][]YtdlpSubtitle) string {
+	if len(subtitles) == 0 {
+		return ""
+	}
+
+	var langs []string
+	for lang, tracks := range subtitles {
+		var exts []string
+		for _, track := range tracks {
+			exts = append(exts, track.Ext)
+		}
+		langs = append(langs, fmt.Sprintf("%s (%s)", lang, strings.Join(exts, ", ")))
+	}
+	sort.Strings(langs)
+	return strings.Join(langs, ", ")
+}
@@ -0,0 +1,41 @@
+// subtitle_test.go
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSubtitleArgs(t *testing.T) {
+	cases := []struct {
+		name         string
+		write, embed bool
+		format       string
+		want         []string
+	}{
+		{"無効", false, false, "srt", nil},
+		{"埋め込みのみ", false, true, "srt", []string{"--sub-langs", "all", "--convert-subs", "srt", "--embed-subs"}},
+		{"保存と埋め込み", true, true, "vtt", []string{"--sub-langs", "all", "--convert-subs", "vtt", "--write-subs", "--embed-subs"}},
+		{"不明な形式", true, false, "ass", []string{"--sub-langs", "all", "--convert-subs", "srt", "--write-subs"}},
+	}
+	for _, c := range cases {
+		d := NewTVerDownloader(".")
+		d.WriteSubtitles, d.EmbedSubtitle, d.SubtitleFormat = c.write, c.embed, c.format
+		if got := d.subtitleArgs(); !slices.Equal(got, c.want) {
+			t.Errorf("%s: subtitleArgs() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDescribeSubtitles(t *testing.T) {
+	subtitles := map[string][]YtdlpSubtitle{
+		"ja": {{Ext: "vtt"}, {Ext: "ttml"}},
+		"en": {{Ext: "vtt"}},
+	}
+	if got, want := describeSubtitles(subtitles), "en (vtt), ja (vtt, ttml)"; got != want {
+		t.Errorf("describeSubtitles = %q, want %q", got, want)
+	}
+	if got := describeSubtitles(nil); got != "" {
+		t.Errorf("字幕なし = %q, want 空", got)
+	}
+}
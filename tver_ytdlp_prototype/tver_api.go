@@ -2,18 +2,39 @@ package main
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// Image URLs on TVer's static CDN.
+const (
+	episodeThumbnailURLFormat = "https://statics.tver.jp/images/content/thumbnail/episode/xlarge/%s.jpg"
+	seriesImageURLFormat      = "https://statics.tver.jp/images/content/thumbnail/series/xlarge/%s.jpg"
+)
+
 // TVerClient manages communication with the TVer API.
 type TVerClient struct {
 	PlatformUID   string
 	PlatformToken string
 	HTTPClient    *http.Client
+
+	// User credentials for MyPage lists. When MemberSID is set the member API
+	// (TVer ID login) is used, otherwise MyPlatformUID/MyPlatformToken of an
+	// anonymous browser session take the place of the token from GetToken.
+	MyPlatformUID   string
+	MyPlatformToken string
+	MemberSID       string
+
+	// ForwardedIP, when set, is sent as X-Forwarded-For and related headers
+	// so that requests appear to come from Japan.
+	ForwardedIP string
 }
 
 // NewTVerClient creates a new TVer API client.
@@ -25,6 +46,14 @@ func NewTVerClient() *TVerClient {
 	}
 }
 
+// setCommonHeaders sets the headers sent with every request.
+func (c *TVerClient) setCommonHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	if c.ForwardedIP != "" {
+		setForwardedHeaders(req.Header, c.ForwardedIP)
+	}
+}
+
 // GetToken fetches an authentication token from the TVer platform API.
 func (c *TVerClient) GetToken() error {
 	url := "https://platform-api.tver.jp/v2/api/platform_users/browser/create"
@@ -35,7 +64,7 @@ func (c *TVerClient) GetToken() error {
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	c.setCommonHeaders(req)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -66,17 +95,14 @@ func (c *TVerClient) GetToken() error {
 	return nil
 }
 
-// GetSeriesSeasons fetches a list of season IDs for a given series ID.
-func (c *TVerClient) GetSeriesSeasons(seriesID string) ([]string, error) {
-	url := fmt.Sprintf("https://platform-api.tver.jp/service/api/v1/callSeriesSeasons/%s?platform_uid=%s&platform_token=%s",
-		seriesID, c.PlatformUID, c.PlatformToken)
-
+// get sends a GET request and returns the response body.
+func (c *TVerClient) get(url string) ([]byte, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("リクエスト作成エラー: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	c.setCommonHeaders(req)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -84,34 +110,95 @@ func (c *TVerClient) GetSeriesSeasons(seriesID string) ([]string, error) {
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("レスポンス読み込みエラー: %w", err)
+	}
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("APIエラー: ステータスコード %d, レスポンス: %s", resp.StatusCode, string(body))
 	}
+	return body, nil
+}
+
+// getJSON sends a GET request to the TVer API and decodes the JSON response into v.
+func (c *TVerClient) getJSON(url string, v interface{}) error {
+	body, err := c.get(url)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("レスポンス解析エラー: %w", err)
+	}
+	return nil
+}
+
+// SeasonInfo identifies a season within a series.
+type SeasonInfo struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// SeriesDetail holds the series-level metadata published on the statics API.
+type SeriesDetail struct {
+	ID          string
+	Title       string
+	Description string
+	Broadcaster string
+}
+
+// GetSeriesDetail fetches the title, description and broadcaster of a series.
+func (c *TVerClient) GetSeriesDetail(seriesID string) (*SeriesDetail, error) {
+	url := fmt.Sprintf("https://statics.tver.jp/content/series/%s.json", seriesID)
+
+	var statics struct {
+		Title                  string `json:"title"`
+		Description            string `json:"description"`
+		BroadcastProviderLabel string `json:"broadcastProviderLabel"`
+	}
+	if err := c.getJSON(url, &statics); err != nil {
+		return nil, err
+	}
+
+	return &SeriesDetail{
+		ID:          seriesID,
+		Title:       strings.TrimSpace(statics.Title),
+		Description: strings.TrimSpace(strings.ReplaceAll(statics.Description, "&amp;", "&")),
+		Broadcaster: strings.TrimSpace(statics.BroadcastProviderLabel),
+	}, nil
+}
+
+// GetSeriesSeasons fetches the seasons (ID and name) of a given series ID.
+func (c *TVerClient) GetSeriesSeasons(seriesID string) ([]SeasonInfo, error) {
+	url := fmt.Sprintf("https://platform-api.tver.jp/service/api/v1/callSeriesSeasons/%s?platform_uid=%s&platform_token=%s",
+		seriesID, c.PlatformUID, c.PlatformToken)
 
 	var apiResp struct {
 		Result struct {
 			Contents []struct {
 				Type    string `json:"Type"`
 				Content struct {
-					ID string `json:"Id"`
+					ID    string `json:"Id"`
+					Title string `json:"Title"`
 				} `json:"Content"`
 			} `json:"Contents"`
 		} `json:"Result"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("レスポンス解析エラー: %w", err)
+	if err := c.getJSON(url, &apiResp); err != nil {
+		return nil, err
 	}
 
-	var seasonIDs []string
+	var seasons []SeasonInfo
 	for _, content := range apiResp.Result.Contents {
 		if content.Type == "season" {
-			seasonIDs = append(seasonIDs, content.Content.ID)
+			seasons = append(seasons, SeasonInfo{
+				ID:    content.Content.ID,
+				Title: strings.TrimSpace(content.Content.Title),
+			})
 		}
 	}
 
-	return seasonIDs, nil
+	return seasons, nil
 }
 
 // GetSeasonEpisodes fetches a list of episodes for a given season ID.
@@ -119,54 +206,553 @@ func (c *TVerClient) GetSeasonEpisodes(seasonID string) ([]EpisodeEntry, error)
 	url := fmt.Sprintf("https://platform-api.tver.jp/service/api/v1/callSeasonEpisodes/%s?platform_uid=%s&platform_token=%s",
 		seasonID, c.PlatformUID, c.PlatformToken)
 
+	var apiResp struct {
+		Result struct {
+			Contents []struct {
+				Type    string `json:"Type"`
+				Content struct {
+					ID              string `json:"Id"`
+					Title           string `json:"Title"`
+					SeriesTitle     string `json:"SeriesTitle"`
+					BroadcasterName string `json:"BroadcasterName"`
+					EndAt           int64  `json:"EndAt"`
+				} `json:"Content"`
+			} `json:"Contents"`
+		} `json:"Result"`
+	}
+
+	if err := c.getJSON(url, &apiResp); err != nil {
+		return nil, err
+	}
+
+	var episodes []EpisodeEntry
+	for _, content := range apiResp.Result.Contents {
+		if content.Type == "episode" {
+			episode := EpisodeEntry{
+				Type:         "video",
+				Title:        content.Content.Title,
+				WebpageURL:   fmt.Sprintf("https://tver.jp/episodes/%s", content.Content.ID),
+				ID:           content.Content.ID,
+				Extractor:    "TVer",
+				ThumbnailURL: fmt.Sprintf(episodeThumbnailURLFormat, content.Content.ID),
+				EndAt:        content.Content.EndAt,
+				SeriesTitle:  strings.TrimSpace(content.Content.SeriesTitle),
+				Broadcaster:  strings.TrimSpace(content.Content.BroadcasterName),
+				SeasonID:     seasonID,
+			}
+			episodes = append(episodes, episode)
+		}
+	}
+
+	return episodes, nil
+}
+
+// EpisodeDetail holds the metadata of a single episode returned by callEpisode and the statics API.
+type EpisodeDetail struct {
+	ID             string `json:"id"`
+	Title          string `json:"title"`
+	SeriesID       string `json:"series_id"`
+	SeriesTitle    string `json:"series_title"`
+	SeasonID       string `json:"season_id"`
+	SeasonTitle    string `json:"season_title"`
+	EpisodeNumber  int    `json:"episode_number"`
+	Broadcaster    string `json:"broadcaster"`
+	Provider       string `json:"provider"`
+	BroadcastLabel string `json:"broadcast_label"`
+	EndAt          int64  `json:"end_at"`
+	Version        int    `json:"version"`
+	Description    string `json:"description"`
+	URL            string `json:"url"`
+	ThumbnailURL   string `json:"thumbnail_url"`
+	SeriesImageURL string `json:"series_image_url"`
+}
+
+// GetEpisodeDetail fetches the metadata of an episode, including its description.
+// If only the statics request fails, the detail from callEpisode is returned
+// together with the error so that the rest of the metadata can still be used.
+func (c *TVerClient) GetEpisodeDetail(episodeID string) (*EpisodeDetail, error) {
+	url := fmt.Sprintf("https://platform-api.tver.jp/service/api/v1/callEpisode/%s?platform_uid=%s&platform_token=%s",
+		episodeID, c.PlatformUID, c.PlatformToken)
+
+	var apiResp struct {
+		Result struct {
+			Episode struct {
+				Content struct {
+					ID                     string      `json:"Id"`
+					Title                  string      `json:"Title"`
+					SeriesTitle            string      `json:"SeriesTitle"`
+					BroadcasterName        string      `json:"BroadcasterName"`
+					ProductionProviderName string      `json:"ProductionProviderName"`
+					BroadcastDateLabel     string      `json:"BroadcastDateLabel"`
+					EndAt                  int64       `json:"EndAt"`
+					Version                json.Number `json:"version"`
+				} `json:"Content"`
+			} `json:"Episode"`
+			Series struct {
+				Content struct {
+					ID string `json:"Id"`
+				} `json:"Content"`
+			} `json:"Series"`
+			Season struct {
+				Content struct {
+					ID    string `json:"Id"`
+					Title string `json:"Title"`
+				} `json:"Content"`
+			} `json:"Season"`
+		} `json:"Result"`
+	}
+
+	if err := c.getJSON(url, &apiResp); err != nil {
+		return nil, err
+	}
+
+	content := apiResp.Result.Episode.Content
+	version, _ := content.Version.Int64()
+	detail := &EpisodeDetail{
+		ID:             content.ID,
+		Title:          strings.TrimSpace(content.Title),
+		SeriesID:       apiResp.Result.Series.Content.ID,
+		SeriesTitle:    strings.TrimSpace(content.SeriesTitle),
+		SeasonID:       apiResp.Result.Season.Content.ID,
+		SeasonTitle:    strings.TrimSpace(apiResp.Result.Season.Content.Title),
+		Broadcaster:    strings.TrimSpace(content.BroadcasterName),
+		Provider:       strings.TrimSpace(content.ProductionProviderName),
+		BroadcastLabel: strings.TrimSpace(content.BroadcastDateLabel),
+		EndAt:          content.EndAt,
+		Version:        int(version),
+		URL:            fmt.Sprintf("https://tver.jp/episodes/%s", content.ID),
+		ThumbnailURL:   fmt.Sprintf(episodeThumbnailURLFormat, content.ID),
+		SeriesImageURL: fmt.Sprintf(seriesImageURLFormat, apiResp.Result.Series.Content.ID),
+	}
+
+	// 番組説明とエピソード番号は statics 側にしかない
+	staticsURL := fmt.Sprintf("https://statics.tver.jp/content/episode/%s.json?v=%d", episodeID, detail.Version)
+	var statics struct {
+		Description string      `json:"Description"`
+		No          json.Number `json:"No"`
+	}
+	if err := c.getJSON(staticsURL, &statics); err != nil {
+		return detail, fmt.Errorf("番組説明取得エラー: %w", err)
+	}
+	detail.Description = strings.TrimSpace(strings.ReplaceAll(statics.Description, "&amp;", "&"))
+	if no, err := statics.No.Int64(); err == nil {
+		detail.EpisodeNumber = int(no)
+	}
+
+	return detail, nil
+}
+
+// DownloadImage saves an image from TVer's CDN to path.
+func (c *TVerClient) DownloadImage(url, path string) error {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("リクエスト作成エラー: %w", err)
+		return fmt.Errorf("リクエスト作成エラー: %w", err)
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	c.setCommonHeaders(req)
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("APIリクエストエラー: %w", err)
+		return fmt.Errorf("画像取得エラー: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("APIエラー: ステータスコード %d, レスポンス: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("画像取得エラー: ステータスコード %d: %s", resp.StatusCode, url)
+	}
+
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("ファイル作成エラー: %w", err)
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("画像書き込みエラー: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("画像書き込みエラー: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// broadcastDatePattern matches the optional year, month and day in a broadcast label.
+var broadcastDatePattern = regexp.MustCompile(`(?:(\d{4})年)?(\d+)月(\d+)日`)
+
+// BroadcastDate parses BroadcastLabel such as "3月17日(月)放送分" into a date.
+// The label has no year, so a date more than a day in the future is taken to be last year's.
+func (d *EpisodeDetail) BroadcastDate() (time.Time, bool) {
+	return parseBroadcastDate(d.BroadcastLabel, time.Now())
+}
+
+// parseBroadcastDate parses a broadcast label relative to now.
+// Labels with a year, such as "2025年03月17日(月)放送" in PowerShell TVerRec history, use that year as is.
+func parseBroadcastDate(label string, now time.Time) (time.Time, bool) {
+	matches := broadcastDatePattern.FindStringSubmatch(label)
+	if len(matches) < 4 {
+		return time.Time{}, false
+	}
+	month, _ := strconv.Atoi(matches[2])
+	day, _ := strconv.Atoi(matches[3])
+
+	if matches[1] != "" {
+		year, _ := strconv.Atoi(matches[1])
+		return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local), true
+	}
+	date := time.Date(now.Year(), time.Month(month), day, 0, 0, 0, 0, time.Local)
+	if date.After(now.AddDate(0, 0, 1)) {
+		date = date.AddDate(-1, 0, 0)
+	}
+	return date, true
+}
+
+// LinkCollection gathers TVer content IDs found by the discovery sources, without duplicates.
+type LinkCollection struct {
+	Episodes   map[string]int64 // episode ID -> EndAt (0 if unknown)
+	Series     []string
+	Seasons    []string
+	Specials   []string // special main pages (callSpecialContents)
+	Categories []string
+	Talents    []string
+
+	SpecialDetails []string // special detail pages (callSpecialContentsDetail)
+
+	episodeOrder []string
+	seen         map[string]bool
+}
+
+// NewLinkCollection creates an empty LinkCollection.
+func NewLinkCollection() *LinkCollection {
+	return &LinkCollection{
+		Episodes: make(map[string]int64),
+		seen:     make(map[string]bool),
+	}
+}
+
+// AddEpisode records an episode, keeping the known EndAt if the episode was already found.
+func (lc *LinkCollection) AddEpisode(id string, endAt int64) {
+	if current, ok := lc.Episodes[id]; ok {
+		if current == 0 {
+			lc.Episodes[id] = endAt
+		}
+		return
+	}
+	lc.Episodes[id] = endAt
+	lc.episodeOrder = append(lc.episodeOrder, id)
+}
+
+// EpisodeIDs returns the episode IDs in the order they were found.
+func (lc *LinkCollection) EpisodeIDs() []string {
+	return lc.episodeOrder
+}
+
+// add appends id to list unless the same kind/id pair was already recorded.
+func (lc *LinkCollection) add(list *[]string, kind, id string) {
+	key := kind + "/" + id
+	if lc.seen[key] {
+		return
+	}
+	lc.seen[key] = true
+	*list = append(*list, id)
+}
+
+// AddLink records a TVer path of the form "<kind>/<id>" such as "episodes/ep123".
+func (lc *LinkCollection) AddLink(kind, id string, episodeOnly bool) bool {
+	if kind == "episodes" {
+		lc.AddEpisode(id, 0)
+		return true
+	}
+	if episodeOnly {
+		return false
+	}
+	switch kind {
+	case "series":
+		lc.add(&lc.Series, kind, id)
+	case "seasons":
+		lc.add(&lc.Seasons, kind, id)
+	case "specials":
+		// サイトマップの specials/<id> は特集の詳細ページ
+		lc.add(&lc.SpecialDetails, "specialDetails", id)
+	case "categories":
+		lc.add(&lc.Categories, kind, id)
+	case "talents":
+		lc.add(&lc.Talents, kind, id)
+	default:
+		return false
+	}
+	return true
+}
+
+// sitemapXML covers both a urlset and a sitemap index.
+type sitemapXML struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// CollectFromSiteMap adds the links listed in tver.jp/sitemap.xml (following sitemap indexes).
+// With episodeOnly, only episode pages are collected.
+func (c *TVerClient) CollectFromSiteMap(lc *LinkCollection, episodeOnly bool) error {
+	return c.collectSiteMap("https://tver.jp/sitemap.xml", lc, episodeOnly, make(map[string]bool))
+}
+
+func (c *TVerClient) collectSiteMap(url string, lc *LinkCollection, episodeOnly bool, visited map[string]bool) error {
+	if visited[url] {
+		return nil
+	}
+	visited[url] = true
+
+	body, err := c.get(url)
+	if err != nil {
+		return fmt.Errorf("サイトマップ取得エラー: %w", err)
+	}
+
+	var sitemap sitemapXML
+	if err := xml.Unmarshal(body, &sitemap); err != nil {
+		return fmt.Errorf("サイトマップ解析エラー: %w", err)
+	}
+
+	for _, child := range sitemap.Sitemaps {
+		if err := c.collectSiteMap(strings.TrimSpace(child.Loc), lc, episodeOnly, visited); err != nil {
+			return err
+		}
+	}
+
+	// specials/xxx/yyy のような詳細ページを拾わないよう、先頭2階層のみを使う
+	for _, u := range sitemap.URLs {
+		path := strings.TrimPrefix(strings.TrimSpace(u.Loc), "https://tver.jp/")
+		parts := strings.Split(path, "/")
+		if len(parts) < 2 || parts[1] == "" {
+			continue
+		}
+		lc.AddLink(parts[0], parts[1], episodeOnly)
+	}
+	return nil
+}
+
+// tverContent is an entry of the Contents lists returned by the platform API.
+type tverContent struct {
+	Type    string `json:"Type"`
+	Content struct {
+		ID    string `json:"Id"`
+		EndAt int64  `json:"EndAt"`
+	} `json:"Content"`
+}
+
+// contentKinds maps platform API content types to LinkCollection kinds.
+var contentKinds = map[string]string{
+	"series": "series",
+	"season": "seasons",
+	"talent": "talents",
+}
+
+// AddContent records a platform API content entry. Live streams and banners are ignored.
+func (lc *LinkCollection) AddContent(content tverContent) {
+	switch content.Type {
+	case "live", "banner":
+		// ライブ配信・バナーはダウンロード対象外
+	case "episode":
+		lc.AddEpisode(content.Content.ID, content.Content.EndAt)
+	case "specialMain":
+		lc.add(&lc.Specials, "specials", content.Content.ID)
+	case "special":
+		lc.add(&lc.SpecialDetails, "specialDetails", content.Content.ID)
+	default:
+		if kind, ok := contentKinds[content.Type]; ok {
+			lc.AddLink(kind, content.Content.ID, false)
+			return
+		}
+		fmt.Printf("不明なコンテンツ種別: %s (%s)\n", content.Type, content.Content.ID)
+	}
+}
+
+// CollectFromTopPage adds the contents shown on the TVer top page (callHome).
+func (c *TVerClient) CollectFromTopPage(lc *LinkCollection) error {
+	url := fmt.Sprintf("https://platform-api.tver.jp/service/api/v1/callHome?platform_uid=%s&platform_token=%s",
+		c.PlatformUID, c.PlatformToken)
+	if err := c.collectHome(url, lc); err != nil {
+		return fmt.Errorf("トップページ取得エラー: %w", err)
+	}
+	return nil
+}
+
+// CollectFromCategory adds the contents shown on a category page such as categories/drama.
+func (c *TVerClient) CollectFromCategory(categoryID string, lc *LinkCollection) error {
+	url := fmt.Sprintf("https://platform-api.tver.jp/service/api/v1/callCategoryHome/%s?platform_uid=%s&platform_token=%s",
+		categoryID, c.PlatformUID, c.PlatformToken)
+	if err := c.collectHome(url, lc); err != nil {
+		return fmt.Errorf("カテゴリ取得エラー: %w", err)
 	}
+	return nil
+}
 
+// collectHome adds the contents of a page made of components (the top page and category pages).
+func (c *TVerClient) collectHome(url string, lc *LinkCollection) error {
 	var apiResp struct {
 		Result struct {
-			Contents []struct {
-				Type    string `json:"Type"`
+			Components []struct {
+				Type     string            `json:"Type"`
+				Contents []json.RawMessage `json:"Contents"`
+			} `json:"Components"`
+		} `json:"Result"`
+	}
+	if err := c.getJSON(url, &apiResp); err != nil {
+		return err
+	}
+
+	for _, component := range apiResp.Result.Components {
+		switch component.Type {
+		case "banner", "resume", "favorite":
+			continue
+		}
+
+		for _, raw := range component.Contents {
+			var content tverContent
+			if component.Type == "topics" {
+				// topicsは Content.Content に実体が入っている
+				var topic struct {
+					Content struct {
+						Content tverContent `json:"Content"`
+					} `json:"Content"`
+				}
+				if err := json.Unmarshal(raw, &topic); err != nil {
+					continue
+				}
+				content = topic.Content.Content
+			} else if err := json.Unmarshal(raw, &content); err != nil {
+				continue
+			}
+			lc.AddContent(content)
+		}
+	}
+	return nil
+}
+
+// CollectFromSpecial adds the contents of a special main page (a specialMain content).
+// The special detail pages it lists are recorded in SpecialDetails.
+func (c *TVerClient) CollectFromSpecial(specialID string, lc *LinkCollection) error {
+	url := fmt.Sprintf("https://platform-api.tver.jp/service/api/v1/callSpecialContents/%s?platform_uid=%s&platform_token=%s",
+		specialID, c.PlatformUID, c.PlatformToken)
+
+	var apiResp struct {
+		Result struct {
+			SpecialContents []tverContent `json:"specialContents"`
+		} `json:"Result"`
+	}
+	if err := c.getJSON(url, &apiResp); err != nil {
+		return fmt.Errorf("特集取得エラー: %w", err)
+	}
+	for _, content := range apiResp.Result.SpecialContents {
+		lc.AddContent(content)
+	}
+	return nil
+}
+
+// CollectFromSpecialDetail adds the contents of a special detail page.
+func (c *TVerClient) CollectFromSpecialDetail(specialID string, lc *LinkCollection) error {
+	url := fmt.Sprintf("https://platform-api.tver.jp/service/api/v1/callSpecialContentsDetail/%s?platform_uid=%s&platform_token=%s",
+		specialID, c.PlatformUID, c.PlatformToken)
+
+	var apiResp struct {
+		Result struct {
+			Contents struct {
 				Content struct {
-					ID    string `json:"Id"`
-					Title string `json:"Title"`
-					EndAt int64  `json:"EndAt"`
+					Contents []tverContent `json:"Contents"`
 				} `json:"Content"`
 			} `json:"Contents"`
 		} `json:"Result"`
 	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("レスポンス解析エラー: %w", err)
+	if err := c.getJSON(url, &apiResp); err != nil {
+		return fmt.Errorf("特集取得エラー: %w", err)
 	}
+	for _, content := range apiResp.Result.Contents.Content.Contents {
+		lc.AddContent(content)
+	}
+	return nil
+}
 
-	var episodes []EpisodeEntry
+// CollectFromTalent adds the episodes a talent appears in (talents/<id>).
+func (c *TVerClient) CollectFromTalent(talentID string, lc *LinkCollection) error {
+	url := fmt.Sprintf("https://platform-api.tver.jp/service/api/v1/callTalentEpisode/%s?platform_uid=%s&platform_token=%s",
+		talentID, c.PlatformUID, c.PlatformToken)
+
+	var apiResp struct {
+		Result struct {
+			Contents []tverContent `json:"Contents"`
+		} `json:"Result"`
+	}
+	if err := c.getJSON(url, &apiResp); err != nil {
+		return fmt.Errorf("タレント取得エラー: %w", err)
+	}
 	for _, content := range apiResp.Result.Contents {
-		if content.Type == "episode" {
-			episode := EpisodeEntry{
-				Type:       "video",
-				Title:      content.Content.Title,
-				WebpageURL: fmt.Sprintf("https://tver.jp/episodes/%s", content.Content.ID),
-				ID:         content.Content.ID,
-				Extractor:  "TVer",
-			}
-			episodes = append(episodes, episode)
-		}
+		lc.AddContent(content)
 	}
+	return nil
+}
 
-	return episodes, nil
+// MyPage lists that can be fetched with user credentials.
+var myPageLists = map[string]struct {
+	path        string
+	requireData string
+}{
+	"fav":      {"/service/api/v2/callMylistDetail/%d", "mylist"},
+	"later":    {"/service/api/v2/callMyLater", "later"},
+	"resume":   {"/service/api/v2/callMyResume", "resume"},
+	"favorite": {"/service/api/v2/callMyFavorite", "mylist"},
+}
+
+// SetUserCredentials configures the credentials used for MyPage lists.
+func (c *TVerClient) SetUserCredentials(platformUID, platformToken, memberSID string) {
+	c.MyPlatformUID = platformUID
+	c.MyPlatformToken = platformToken
+	c.MemberSID = memberSID
+}
+
+// HasUserCredentials reports whether MyPage lists can be fetched.
+func (c *TVerClient) HasUserCredentials() bool {
+	return c.MemberSID != "" || (c.MyPlatformUID != "" && c.MyPlatformToken != "")
+}
+
+// CollectFromMyPage adds the contents of a MyPage list (fav, later, resume or favorite).
+func (c *TVerClient) CollectFromMyPage(page string, lc *LinkCollection) error {
+	list, ok := myPageLists[page]
+	if !ok {
+		return fmt.Errorf("不明なマイページ: %s (fav, later, resume, favorite のいずれかを指定してください)", page)
+	}
+	if !c.HasUserCredentials() {
+		return fmt.Errorf("マイページの取得にはplatform_uidとplatform_token、またはmember_sidが必要です")
+	}
+
+	path := list.path
+	if strings.Contains(path, "%d") {
+		path = fmt.Sprintf(path, time.Now().Unix())
+	}
+
+	var url string
+	if c.MemberSID != "" {
+		url = fmt.Sprintf("https://member-api.tver.jp%s?member_sid=%s", path, c.MemberSID)
+	} else {
+		url = fmt.Sprintf("https://platform-api.tver.jp%s?platform_uid=%s&platform_token=%s",
+			path, c.MyPlatformUID, c.MyPlatformToken)
+	}
+	url += "&require_data=" + list.requireData
+
+	var apiResp struct {
+		Result struct {
+			Contents []tverContent `json:"Contents"`
+		} `json:"Result"`
+	}
+	if err := c.getJSON(url, &apiResp); err != nil {
+		return fmt.Errorf("マイページ取得エラー: %w", err)
+	}
+
+	for _, content := range apiResp.Result.Contents {
+		lc.AddContent(content)
+	}
+	return nil
 }
@@ -0,0 +1,277 @@
+// tver_api_test.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TVerのAPIへのリクエストをテスト用サーバーに向けるTransport
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Original-Host", req.URL.Host)
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// handlerへリクエストを送るクライアントを作成 (元のホスト名は X-Original-Host で分かる)
+func newTestTVerClient(t *testing.T, handler http.HandlerFunc) *TVerClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+
+	client := NewTVerClient()
+	client.HTTPClient.Transport = rewriteTransport{target: target}
+	return client
+}
+
+func TestParseBroadcastDate(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.Local)
+	cases := []struct {
+		label string
+		want  time.Time
+		ok    bool
+	}{
+		{"3月17日(月)放送分", time.Date(2025, 3, 17, 0, 0, 0, 0, time.Local), true},
+		{"3月21日(金)放送分", time.Date(2025, 3, 21, 0, 0, 0, 0, time.Local), true},
+		{"12月31日(火)放送分", time.Date(2024, 12, 31, 0, 0, 0, 0, time.Local), true},
+		// PowerShell版の履歴は年を含み、未来の日付でもその年のまま
+		{"2025年03月17日(月)放送", time.Date(2025, 3, 17, 0, 0, 0, 0, time.Local), true},
+		{"2023年12月31日(日)放送", time.Date(2023, 12, 31, 0, 0, 0, 0, time.Local), true},
+		{"2025年12月31日(水)放送", time.Date(2025, 12, 31, 0, 0, 0, 0, time.Local), true},
+		{"配信中", time.Time{}, false},
+	}
+	for _, c := range cases {
+		got, ok := parseBroadcastDate(c.label, now)
+		if ok != c.ok || !got.Equal(c.want) {
+			t.Errorf("parseBroadcastDate(%q) = %v, %v; want %v, %v", c.label, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestGetEpisodeDetailWithoutStatics(t *testing.T) {
+	client := newTestTVerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Original-Host") == "statics.tver.jp" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"Result": {
+			"Episode": {"Content": {"Id": "ep1", "Title": " 第1話 ", "SeriesTitle": "番組", "BroadcasterName": "テレビ局",
+				"BroadcastDateLabel": "3月17日(月)放送分", "EndAt": 1742400000, "version": 5}},
+			"Series": {"Content": {"Id": "sr1"}},
+			"Season": {"Content": {"Id": "ss1", "Title": "本編"}}}}`))
+	})
+
+	detail, err := client.GetEpisodeDetail("ep1")
+	if err == nil {
+		t.Fatal("statics の取得失敗がエラーになっていません")
+	}
+	if detail == nil {
+		t.Fatal("callEpisode の番組情報が返されていません")
+	}
+	if detail.Title != "第1話" || detail.SeriesID != "sr1" || detail.SeasonTitle != "本編" || detail.Version != 5 || detail.EndAt != 1742400000 {
+		t.Errorf("detail = %+v", detail)
+	}
+	if detail.Description != "" || detail.EpisodeNumber != 0 {
+		t.Errorf("statics の項目が設定されています: %+v", detail)
+	}
+}
+
+func TestGetEpisodeDetail(t *testing.T) {
+	client := newTestTVerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Original-Host") == "statics.tver.jp" {
+			if got := r.URL.Query().Get("v"); got != "5" {
+				t.Errorf("statics のバージョン = %q, want 5", got)
+			}
+			w.Write([]byte(`{"Description": "A&amp;B ", "No": 3}`))
+			return
+		}
+		w.Write([]byte(`{"Result": {"Episode": {"Content": {"Id": "ep1", "Title": "第3話", "version": "5"}}}}`))
+	})
+
+	detail, err := client.GetEpisodeDetail("ep1")
+	if err != nil {
+		t.Fatalf("GetEpisodeDetail: %v", err)
+	}
+	if detail.Description != "A&B" || detail.EpisodeNumber != 3 {
+		t.Errorf("detail = %+v", detail)
+	}
+}
+
+func TestLinkCollection(t *testing.T) {
+	lc := NewLinkCollection()
+	lc.AddEpisode("ep1", 0)
+	lc.AddEpisode("ep2", 200)
+	lc.AddEpisode("ep1", 100) // 配信終了日時が不明だったものは補う
+	lc.AddEpisode("ep2", 300) // 既知の配信終了日時は変えない
+
+	cases := []struct {
+		kind, id    string
+		episodeOnly bool
+		want        bool
+	}{
+		{"episodes", "ep3", true, true},
+		{"series", "sr1", true, false},
+		{"series", "sr1", false, true},
+		{"series", "sr1", false, true},
+		{"seasons", "ss1", false, true},
+		{"specials", "sp1", false, true},
+		{"categories", "drama", false, true},
+		{"talents", "t1", false, true},
+		{"ranking", "all", false, false},
+	}
+	for _, c := range cases {
+		if got := lc.AddLink(c.kind, c.id, c.episodeOnly); got != c.want {
+			t.Errorf("AddLink(%s, %s, %v) = %v, want %v", c.kind, c.id, c.episodeOnly, got, c.want)
+		}
+	}
+
+	if got := strings.Join(lc.EpisodeIDs(), ","); got != "ep1,ep2,ep3" {
+		t.Errorf("EpisodeIDs = %s", got)
+	}
+	if lc.Episodes["ep1"] != 100 || lc.Episodes["ep2"] != 200 {
+		t.Errorf("Episodes = %v", lc.Episodes)
+	}
+	if len(lc.Series) != 1 || len(lc.Seasons) != 1 || len(lc.SpecialDetails) != 1 || len(lc.Categories) != 1 || len(lc.Talents) != 1 {
+		t.Errorf("lc = %+v", lc)
+	}
+}
+
+func TestAddContent(t *testing.T) {
+	lc := NewLinkCollection()
+	for _, raw := range []string{
+		`{"Type": "episode", "Content": {"Id": "ep1", "EndAt": 100}}`,
+		`{"Type": "live", "Content": {"Id": "live1"}}`,
+		`{"Type": "season", "Content": {"Id": "ss1"}}`,
+		`{"Type": "specialMain", "Content": {"Id": "main1"}}`,
+		`{"Type": "special", "Content": {"Id": "detail1"}}`,
+		`{"Type": "talent", "Content": {"Id": "t1"}}`,
+	} {
+		var content tverContent
+		if err := json.Unmarshal([]byte(raw), &content); err != nil {
+			t.Fatal(err)
+		}
+		lc.AddContent(content)
+	}
+
+	if lc.Episodes["ep1"] != 100 || len(lc.Episodes) != 1 {
+		t.Errorf("Episodes = %v", lc.Episodes)
+	}
+	if !slices.Equal(lc.Seasons, []string{"ss1"}) || !slices.Equal(lc.Specials, []string{"main1"}) ||
+		!slices.Equal(lc.SpecialDetails, []string{"detail1"}) || !slices.Equal(lc.Talents, []string{"t1"}) {
+		t.Errorf("lc = %+v", lc)
+	}
+}
+
+func TestCollectFromSiteMap(t *testing.T) {
+	client := newTestTVerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://tver.jp/sitemap-1.xml</loc></sitemap>
+  <sitemap><loc>https://tver.jp/sitemap-1.xml</loc></sitemap>
+</sitemapindex>`))
+		case "/sitemap-1.xml":
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://tver.jp/episodes/ep1 </loc></url>
+  <url><loc>https://tver.jp/series/sr1</loc></url>
+  <url><loc>https://tver.jp/specials/sp1/detail1</loc></url>
+  <url><loc>https://tver.jp/categories/drama</loc></url>
+  <url><loc>https://tver.jp/ranking</loc></url>
+  <url><loc>https://tver.jp/series/</loc></url>
+</urlset>`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	cases := []struct {
+		name        string
+		episodeOnly bool
+		series      int
+		specials    int
+		categories  int
+	}{
+		{"すべて", false, 1, 1, 1},
+		{"エピソードのみ", true, 0, 0, 0},
+	}
+	for _, c := range cases {
+		lc := NewLinkCollection()
+		if err := client.CollectFromSiteMap(lc, c.episodeOnly); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := strings.Join(lc.EpisodeIDs(), ","); got != "ep1" {
+			t.Errorf("%s: EpisodeIDs = %s", c.name, got)
+		}
+		if len(lc.Series) != c.series || len(lc.SpecialDetails) != c.specials || len(lc.Categories) != c.categories {
+			t.Errorf("%s: lc = %+v", c.name, lc)
+		}
+	}
+}
+
+func TestCollectFromSiteMapError(t *testing.T) {
+	client := newTestTVerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>"))
+	})
+	if err := client.CollectFromSiteMap(NewLinkCollection(), false); err == nil {
+		t.Error("解析できないサイトマップがエラーになっていません")
+	}
+}
+
+func TestCollectFromMyPage(t *testing.T) {
+	cases := []struct {
+		name       string
+		uid, token string
+		memberSID  string
+		wantHost   string
+		wantQuery  string
+	}{
+		{"ブラウザのセッション", "uid1", "token1", "", "platform-api.tver.jp", "platform_uid=uid1"},
+		{"TVer ID", "", "", "sid1", "member-api.tver.jp", "member_sid=sid1"},
+	}
+	for _, c := range cases {
+		client := newTestTVerClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if host := r.Header.Get("X-Original-Host"); host != c.wantHost {
+				t.Errorf("%s: ホスト = %s, want %s", c.name, host, c.wantHost)
+			}
+			if !strings.Contains(r.URL.RawQuery, c.wantQuery) || r.URL.Query().Get("require_data") != "mylist" {
+				t.Errorf("%s: クエリ = %s", c.name, r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"Result": {"Contents": [
+				{"Type": "series", "Content": {"Id": "sr1"}},
+				{"Type": "talent", "Content": {"Id": "t1"}}]}}`))
+		})
+		client.SetUserCredentials(c.uid, c.token, c.memberSID)
+
+		lc := NewLinkCollection()
+		if err := client.CollectFromMyPage("favorite", lc); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !slices.Equal(lc.Series, []string{"sr1"}) || !slices.Equal(lc.Talents, []string{"t1"}) {
+			t.Errorf("%s: lc = %+v", c.name, lc)
+		}
+	}
+
+	client := NewTVerClient()
+	if err := client.CollectFromMyPage("favorite", NewLinkCollection()); err == nil {
+		t.Error("認証情報なしでエラーになっていません")
+	}
+	client.SetUserCredentials("uid1", "token1", "")
+	if err := client.CollectFromMyPage("history", NewLinkCollection()); err == nil {
+		t.Error("不明なマイページがエラーになっていません")
+	}
+}
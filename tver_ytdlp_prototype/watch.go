@@ -0,0 +1,331 @@
+// watch.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// series watch の終了コード (grepと同様に 0:新着あり 1:エラー 2:新着なし)
+const (
+	watchExitNewEpisodes = 0
+	watchExitError       = 1
+	watchExitNoChanges   = 2
+)
+
+// 監視対象シリーズの前回確認時の状態
+type WatchSnapshot struct {
+	Series map[string]*WatchedSeries `json:"series"`
+}
+
+// シリーズごとの既知エピソード
+type WatchedSeries struct {
+	Title     string                    `json:"title"`
+	CheckedAt time.Time                 `json:"checked_at"`
+	Episodes  map[string]WatchedEpisode `json:"episodes"`
+}
+
+// 既知エピソード
+type WatchedEpisode struct {
+	Title string `json:"title"`
+	EndAt int64  `json:"end_at"`
+}
+
+// シリーズごとの確認結果
+type WatchResult struct {
+	SeriesID string
+	Title    string
+	Added    []ParsedEpisode
+	Removed  []WatchedEpisode
+	Failed   []ParsedEpisode
+}
+
+// series watch の動作設定
+type WatchOptions struct {
+	StatePath string // 状態ファイルのパス
+	Baseline  bool   // ダウンロードせずに現在のエピソードを既知として記録する
+	DryRun    bool   // 差分の表示のみ (ダウンロードも状態の更新もしない)
+}
+
+// 状態ファイルを読み込み (存在しなければ空の状態を返す)
+func LoadWatchSnapshot(path string) (*WatchSnapshot, error) {
+	snapshot := &WatchSnapshot{Series: make(map[string]*WatchedSeries)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("状態ファイル読み込みエラー: %w", err)
+	}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("状態ファイル解析エラー: %w", err)
+	}
+	if snapshot.Series == nil {
+		snapshot.Series = make(map[string]*WatchedSeries)
+	}
+	return snapshot, nil
+}
+
+// 状態ファイルを保存 (一時ファイル経由で置き換え)
+func (s *WatchSnapshot) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("JSON生成エラー: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("状態ファイル書き込みエラー: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("状態ファイル書き込みエラー: %w", err)
+	}
+	return nil
+}
+
+// エピソードを既知のエピソードから外し、外したらtrue (次回の確認で新着として扱われる)
+func (s *WatchSnapshot) Forget(episodeID string) bool {
+	found := false
+	for _, series := range s.Series {
+		if _, ok := series.Episodes[episodeID]; ok {
+			delete(series.Episodes, episodeID)
+			found = true
+		}
+	}
+	return found
+}
+
+// 監視対象の指定(カンマ区切りのシリーズIDまたはURL、もしくは1行1件のリストファイル)を解析
+func ParseWatchTargets(target string) ([]string, error) {
+	var entries []string
+	if file, err := os.Open(target); err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			entries = append(entries, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("リストファイル読み込みエラー: %w", err)
+		}
+	} else {
+		entries = strings.Split(target, ",")
+	}
+
+	sm := NewSeriesManager()
+	seen := make(map[string]bool)
+	var seriesIDs []string
+	for _, entry := range entries {
+		// コメントと空行は無視
+		if i := strings.Index(entry, "#"); i >= 0 {
+			entry = entry[:i]
+		}
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		seriesID := entry
+		if strings.Contains(entry, "series/") {
+			id, err := sm.extractSeriesID(entry)
+			if err != nil {
+				return nil, err
+			}
+			seriesID = id
+		}
+		if !seen[seriesID] {
+			seen[seriesID] = true
+			seriesIDs = append(seriesIDs, seriesID)
+		}
+	}
+
+	if len(seriesIDs) == 0 {
+		return nil, fmt.Errorf("監視対象のシリーズがありません: %s", target)
+	}
+	return seriesIDs, nil
+}
+
+// 前回の状態と現在のエピソード一覧を比較
+func diffWatchedSeries(previous *WatchedSeries, episodes []ParsedEpisode) (added []ParsedEpisode, removed []WatchedEpisode) {
+	current := make(map[string]bool)
+	for _, ep := range episodes {
+		current[ep.ID] = true
+		if _, ok := previous.Episodes[ep.ID]; !ok {
+			added = append(added, ep)
+		}
+	}
+
+	var removedIDs []string
+	for id := range previous.Episodes {
+		if !current[id] {
+			removedIDs = append(removedIDs, id)
+		}
+	}
+	sort.Strings(removedIDs)
+	for _, id := range removedIDs {
+		removed = append(removed, previous.Episodes[id])
+	}
+	return added, removed
+}
+
+// 1シリーズ分の新着を確認してダウンロード
+func watchSeries(sm *SeriesManager, downloader *TVerDownloader, snapshot *WatchSnapshot, seriesID string, opts WatchOptions) (*WatchResult, error) {
+	seriesInfo, err := sm.GetSeriesInfo(fmt.Sprintf("https://tver.jp/series/%s", seriesID))
+	if err != nil {
+		return nil, err
+	}
+	// 一部のシーズンだけで比較すると、取得できなかったエピソードを配信終了とみなして
+	// 記録から外し、次回に新着として再ダウンロードしてしまうため、前回の状態のまま残す
+	if len(seriesInfo.FailedSeasons) > 0 {
+		return nil, fmt.Errorf("%d件のシーズンのエピソードを取得できませんでした (%s)",
+			len(seriesInfo.FailedSeasons), seriesInfo.FailedSeasons[0].ID)
+	}
+	episodes := sm.ParseEpisodes(seriesInfo)
+	if downloader.Catalog != nil {
+		if err := downloader.Catalog.UpsertSeries(seriesInfo, episodes); err != nil {
+			log.Printf("カタログ記録エラー: %v", err)
+		}
+	}
+
+	previous, ok := snapshot.Series[seriesID]
+	if !ok {
+		previous = &WatchedSeries{Episodes: make(map[string]WatchedEpisode)}
+	}
+
+	result := &WatchResult{SeriesID: seriesID, Title: seriesInfo.Title}
+	result.Added, result.Removed = diffWatchedSeries(previous, episodes)
+	if len(result.Added) > 0 && !opts.Baseline {
+		titles := make([]string, len(result.Added))
+		for i, ep := range result.Added {
+			titles[i] = ep.Title
+		}
+		downloader.notify(notifyNewEpisodes, fmt.Sprintf("新着 %d話: %s", len(result.Added), seriesInfo.Title),
+			strings.Join(titles, "\n"), fmt.Sprintf("https://tver.jp/series/%s", seriesID))
+	}
+	if opts.DryRun {
+		return result, nil
+	}
+
+	// 次回の比較用に現在のエピソードを記録 (ダウンロードに失敗したものは記録せず次回再試行)
+	next := &WatchedSeries{
+		Title:     seriesInfo.Title,
+		CheckedAt: time.Now(),
+		Episodes:  make(map[string]WatchedEpisode),
+	}
+	for _, ep := range episodes {
+		var endAt int64
+		if !ep.EndAt.IsZero() {
+			endAt = ep.EndAt.Unix()
+		}
+		next.Episodes[ep.ID] = WatchedEpisode{Title: ep.Title, EndAt: endAt}
+	}
+
+	if !opts.Baseline && len(result.Added) > 0 {
+		seriesDownloader, err := downloader.ForSeries(seriesInfo.Title)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ep := range sm.SortByExpiry(result.Added) {
+			fmt.Printf("\n新着ダウンロード: %s\n", ep.Title)
+			if err := seriesDownloader.DownloadVideo(ep.URL); err != nil {
+				if errors.Is(err, ErrAlreadyDownloaded) {
+					fmt.Printf("ダウンロード済みのためスキップ: %s\n", ep.Title)
+					continue
+				}
+				if errors.Is(err, ErrInsufficientDiskSpace) {
+					return nil, err
+				}
+				log.Printf("エピソード %s のダウンロードエラー: %v", ep.ID, err)
+				downloader.notify(notifyDownloadFailed, "ダウンロード失敗: "+ep.Title, err.Error(), ep.URL)
+				result.Failed = append(result.Failed, ep)
+				delete(next.Episodes, ep.ID)
+			}
+		}
+	}
+
+	snapshot.Series[seriesID] = next
+	return result, nil
+}
+
+// 監視対象シリーズをまとめて確認し、終了コードを返す
+func RunSeriesWatch(downloader *TVerDownloader, seriesIDs []string, opts WatchOptions) (int, error) {
+	snapshot, err := LoadWatchSnapshot(opts.StatePath)
+	if err != nil {
+		return watchExitError, err
+	}
+
+	client, err := downloader.tverClient()
+	if err != nil {
+		return watchExitError, err
+	}
+	sm := NewSeriesManager()
+	sm.Client = client
+
+	var results []*WatchResult
+	var failedSeries []string
+	for _, seriesID := range seriesIDs {
+		fmt.Printf("\n=== シリーズ確認: %s ===\n", seriesID)
+		result, err := watchSeries(sm, downloader, snapshot, seriesID, opts)
+		if errors.Is(err, ErrInsufficientDiskSpace) {
+			return watchExitError, err
+		}
+		if err != nil {
+			log.Printf("シリーズ %s の確認エラー: %v", seriesID, err)
+			failedSeries = append(failedSeries, seriesID)
+			continue
+		}
+		results = append(results, result)
+
+		// 途中で中断しても確認済みのシリーズは次回に引き継ぐ
+		if !opts.DryRun {
+			if err := snapshot.Save(opts.StatePath); err != nil {
+				return watchExitError, err
+			}
+		}
+	}
+
+	displayWatchResults(results)
+
+	// 確認できなかったシリーズがあれば、新着の有無にかかわらずエラーとして知らせる
+	if len(failedSeries) > 0 {
+		fmt.Printf("確認に失敗したシリーズ: %s\n", strings.Join(failedSeries, ", "))
+		return watchExitError, nil
+	}
+	for _, result := range results {
+		if len(result.Added) > 0 {
+			return watchExitNewEpisodes, nil
+		}
+	}
+	return watchExitNoChanges, nil
+}
+
+// シリーズごとの追加・削除を表示
+func displayWatchResults(results []*WatchResult) {
+	fmt.Println("\n=== 新着確認結果 ===")
+	for _, result := range results {
+		fmt.Printf("%s (%s): 追加 %d話 / 配信終了 %d話", result.Title, result.SeriesID, len(result.Added), len(result.Removed))
+		if len(result.Failed) > 0 {
+			fmt.Printf(" / 失敗 %d話", len(result.Failed))
+		}
+		fmt.Println()
+		for _, ep := range result.Added {
+			fmt.Printf("  + %s\n", ep.Title)
+		}
+		for _, ep := range result.Removed {
+			fmt.Printf("  - %s\n", ep.Title)
+		}
+		for _, ep := range result.Failed {
+			fmt.Printf("  ! %s (次回再試行)\n", ep.Title)
+		}
+	}
+	fmt.Println("==================")
+}
@@ -0,0 +1,99 @@
+// watch_test.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDiffWatchedSeries(t *testing.T) {
+	previous := &WatchedSeries{Episodes: map[string]WatchedEpisode{
+		"ep1": {Title: "第1話"},
+		"ep2": {Title: "第2話"},
+		"ep0": {Title: "第0話"},
+	}}
+	episodes := []ParsedEpisode{{ID: "ep1"}, {ID: "ep3", Title: "第3話"}, {ID: "ep2"}, {ID: "ep4"}}
+
+	added, removed := diffWatchedSeries(previous, episodes)
+	if ids := episodeIDs(added); ids != "ep3,ep4" {
+		t.Errorf("added = %s, want ep3,ep4", ids)
+	}
+	if len(removed) != 1 || removed[0].Title != "第0話" {
+		t.Errorf("removed = %+v, want 第0話", removed)
+	}
+
+	// 初回はすべて新着
+	added, removed = diffWatchedSeries(&WatchedSeries{}, episodes)
+	if len(added) != 4 || len(removed) != 0 {
+		t.Errorf("初回: added = %d, removed = %d", len(added), len(removed))
+	}
+}
+
+func TestParseWatchTargets(t *testing.T) {
+	listPath := filepath.Join(t.TempDir(), "watch.txt")
+	writeTestFile(t, listPath, "# 毎週見る番組\nsr111\n\nhttps://tver.jp/series/sr222?utm=x  # URLでも可\nsr111\n")
+
+	cases := []struct {
+		target string
+		want   []string
+	}{
+		{"sr111,sr222", []string{"sr111", "sr222"}},
+		{" sr111 , https://tver.jp/series/sr333 ,sr111", []string{"sr111", "sr333"}},
+		{listPath, []string{"sr111", "sr222"}},
+	}
+	for _, c := range cases {
+		got, err := ParseWatchTargets(c.target)
+		if err != nil || !slices.Equal(got, c.want) {
+			t.Errorf("ParseWatchTargets(%q) = %v, %v; want %v", c.target, got, err, c.want)
+		}
+	}
+	if _, err := ParseWatchTargets(" , # なし"); err == nil {
+		t.Error("対象がない指定がエラーになっていません")
+	}
+}
+
+func TestRunSeriesWatchExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "watch_state.json")
+	run := func(episodes map[string]string, seriesIDs ...string) int {
+		t.Helper()
+		downloader := NewTVerDownloader(dir)
+		downloader.Client = newTestTVerClient(t, seriesAPIHandler(t, testSeasonsResponse, episodes, ""))
+		code, err := RunSeriesWatch(downloader, seriesIDs, WatchOptions{StatePath: statePath, Baseline: true})
+		if err != nil {
+			t.Fatalf("RunSeriesWatch: %v", err)
+		}
+		return code
+	}
+
+	if code := run(testSeasonEpisodes, "sr1"); code != watchExitNewEpisodes {
+		t.Errorf("初回 = %d, want %d", code, watchExitNewEpisodes)
+	}
+	if code := run(testSeasonEpisodes, "sr1"); code != watchExitNoChanges {
+		t.Errorf("変更なし = %d, want %d", code, watchExitNoChanges)
+	}
+	before, _ := os.ReadFile(statePath)
+
+	// シーズンの取得に失敗したシリーズは状態を変えずにエラーとする
+	partial := map[string]string{"ss1": testSeasonEpisodes["ss1"]}
+	if code := run(partial, "sr1"); code != watchExitError {
+		t.Errorf("シーズン取得失敗 = %d, want %d", code, watchExitError)
+	}
+	if after, _ := os.ReadFile(statePath); string(after) != string(before) {
+		t.Errorf("シーズン取得失敗で状態が変わりました:\n%s", after)
+	}
+	snapshot, err := LoadWatchSnapshot(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snapshot.Series["sr1"].Episodes["sp1"]; !ok {
+		t.Error("取得できなかったシーズンのエピソードが記録から外れました")
+	}
+
+	// 他のシリーズで新着があっても、確認に失敗したシリーズがあればエラー
+	if code := run(partial, "sr2", "sr1"); code != watchExitError {
+		t.Errorf("一部のシリーズが失敗 = %d, want %d", code, watchExitError)
+	}
+}
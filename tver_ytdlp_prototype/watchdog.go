@@ -0,0 +1,217 @@
+// watchdog.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 監視ループの確認間隔
+const watchdogInterval = 5 * time.Second
+
+// 強制終了後に子プロセスが出力を閉じるまで待つ時間
+const killWaitDelay = 10 * time.Second
+
+// ダウンロード中断時のエラー
+var (
+	ErrDownloadTimeout = errors.New("ダウンロードがタイムアウトしました")
+	ErrDownloadStalled = errors.New("ダウンロードが停止しました")
+)
+
+// yt-dlpの進捗行 ([download]  12.3% of ~ 1.23GiB at 3.45MiB/s ETA 05:12 (frag 10/300) など)
+var (
+	downloadPercentPattern  = regexp.MustCompile(`^\[download\]\s+([\d.]+)%`)
+	downloadBytesPattern    = regexp.MustCompile(`^\[download\]\s+([\d.]+)([KMGT]?i?B) at`)
+	downloadFragmentPattern = regexp.MustCompile(`\(frag (\d+)/\d+\)`)
+)
+
+// ダウンロードの進み具合 (行に含まれない値は0)
+type downloadProgress struct {
+	Percent  float64
+	Bytes    float64 // 全体のサイズが不明な場合に表示される取得済みのバイト数
+	Fragment int
+}
+
+// 前回より進んでいるか
+func (p downloadProgress) advancedFrom(prev downloadProgress) bool {
+	return p.Percent > prev.Percent || p.Bytes > prev.Bytes || p.Fragment > prev.Fragment
+}
+
+// yt-dlpの [download] 進捗行を解析 (進捗行でなければfalse)
+func parseDownloadProgress(line string) (downloadProgress, bool) {
+	var progress downloadProgress
+	var ok bool
+	if m := downloadPercentPattern.FindStringSubmatch(line); m != nil {
+		progress.Percent, _ = strconv.ParseFloat(m[1], 64)
+		ok = true
+	} else if m := downloadBytesPattern.FindStringSubmatch(line); m != nil {
+		value, _ := strconv.ParseFloat(m[1], 64)
+		progress.Bytes = value * float64(byteUnits[m[2]])
+		ok = true
+	}
+	if !ok {
+		return downloadProgress{}, false
+	}
+	if m := downloadFragmentPattern.FindStringSubmatch(line); m != nil {
+		progress.Fragment, _ = strconv.Atoi(m[1])
+	}
+	return progress, true
+}
+
+// yt-dlpが表示するサイズの単位
+var byteUnits = map[string]int64{
+	"B": 1, "KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40,
+	"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+}
+
+// yt-dlpの標準出力を中継しつつ進捗の有無を記録するWriter
+//
+// 再試行やエラーの行が出続けていても進んでいないため、[download] 行の
+// 進捗率・バイト数・フラグメント番号が増えたときだけ進捗ありとみなす。
+type progressWriter struct {
+	out      io.Writer
+	onLine   func(line string)
+	mu       sync.Mutex
+	buf      []byte
+	lastLine string
+	progress downloadProgress
+	lastSeen time.Time
+}
+
+// 新しい進捗監視Writerを作成
+func newProgressWriter(out io.Writer, onLine func(line string)) *progressWriter {
+	return &progressWriter{
+		out:      out,
+		onLine:   onLine,
+		lastSeen: time.Now(),
+	}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+		if line == "" || line == w.lastLine {
+			continue
+		}
+		w.lastLine = line
+		w.observe(line, time.Now())
+		if w.onLine != nil {
+			w.onLine(line)
+		}
+	}
+
+	return w.out.Write(p)
+}
+
+// 1行を確認し、ダウンロードが進んでいれば進捗時刻を更新 (w.muを保持して呼ぶ)
+func (w *progressWriter) observe(line string, now time.Time) {
+	// 次のファイル(動画・音声・字幕)のダウンロード開始で進捗率は0に戻る
+	if strings.HasPrefix(line, "[download] Destination:") {
+		w.progress = downloadProgress{}
+		w.lastSeen = now
+		return
+	}
+	if progress, ok := parseDownloadProgress(line); ok && progress.advancedFrom(w.progress) {
+		w.progress = progress
+		w.lastSeen = now
+	}
+}
+
+// 最後に進捗があってからの経過時間
+func (w *progressWriter) idle() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return time.Since(w.lastSeen)
+}
+
+// yt-dlpを実行し、タイムアウトと停止を監視
+func (d *TVerDownloader) runYtdlp(args []string) error {
+	parent := d.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	if d.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, d.Timeout)
+		defer cancelTimeout()
+	}
+
+	progress := newProgressWriter(os.Stdout, d.OnProgress)
+	cmd := exec.CommandContext(ctx, d.YtdlpPath, args...)
+	cmd.Stdout = progress
+	cmd.Stderr = os.Stderr
+	cmd.WaitDelay = killWaitDelay
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("yt-dlp起動エラー: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	var stalled bool
+	var diskErr error
+	for {
+		select {
+		case err := <-done:
+			switch {
+			case diskErr != nil:
+				return diskErr
+			case stalled:
+				return fmt.Errorf("%w (%v以上進捗なし)", ErrDownloadStalled, d.StallTimeout)
+			case errors.Is(ctx.Err(), context.DeadlineExceeded):
+				return fmt.Errorf("%w (%v経過)", ErrDownloadTimeout, d.Timeout)
+			case parent.Err() != nil:
+				return fmt.Errorf("ダウンロードを中断しました: %w", parent.Err())
+			}
+			return err
+		case <-ticker.C:
+			if !stalled && d.StallTimeout > 0 && progress.idle() > d.StallTimeout {
+				fmt.Printf("ダウンロードが%v以上進んでいないため強制終了します\n", d.StallTimeout)
+				stalled = true
+				cancel()
+			}
+			if diskErr == nil && !stalled {
+				if err := d.checkDiskSpace(); errors.Is(err, ErrInsufficientDiskSpace) {
+					fmt.Printf("%v\nダウンロードを中断します\n", err)
+					diskErr = err
+					cancel()
+				}
+			}
+		}
+	}
+}
+
+// yt-dlpの一時ファイルかどうかを判定
+func isPartialFile(name string) bool {
+	return strings.Contains(name, ".part") ||
+		strings.Contains(name, "-Frag") ||
+		strings.HasSuffix(name, ".ytdl") ||
+		strings.HasSuffix(name, ".temp")
+}
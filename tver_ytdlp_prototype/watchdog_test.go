@@ -0,0 +1,89 @@
+// watchdog_test.go
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParseDownloadProgress(t *testing.T) {
+	cases := []struct {
+		line string
+		want downloadProgress
+		ok   bool
+	}{
+		{"[download]  12.3% of ~   1.23GiB at    3.45MiB/s ETA 05:12 (frag 10/300)", downloadProgress{Percent: 12.3, Fragment: 10}, true},
+		{"[download] 100% of  123.45MiB in 00:01:23 at 1.50MiB/s", downloadProgress{Percent: 100}, true},
+		{"[download]   2.00MiB at  512.00KiB/s (00:00:04)", downloadProgress{Bytes: 2 << 20}, true},
+		{"[download] Destination: /tmp/job/番組 - 第1話.mp4", downloadProgress{}, false},
+		{"[download] Got error: HTTP Error 503. Retrying fragment 11 (1/10)...", downloadProgress{}, false},
+		{"[hlsnative] Downloading m3u8 manifest", downloadProgress{}, false},
+		{"ERROR: unable to download video data", downloadProgress{}, false},
+	}
+	for _, c := range cases {
+		got, ok := parseDownloadProgress(c.line)
+		if ok != c.ok || got != c.want {
+			t.Errorf("parseDownloadProgress(%q) = %+v, %v; want %+v, %v", c.line, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestProgressWriterIgnoresRetries(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	cases := []struct {
+		name     string
+		lines    []string
+		advanced bool
+	}{
+		{"進捗率の増加", []string{"[download]  10.0% of 1.00GiB", "[download]  10.5% of 1.00GiB"}, true},
+		{"フラグメントの増加", []string{"[download]  10.0% of ~ 1.00GiB (frag 3/600)", "[download]  10.0% of ~ 1.01GiB (frag 4/600)"}, true},
+		{"次のファイル", []string{"[download] 100% of 1.00GiB", "[download] Destination: b.m4a"}, true},
+		{"再試行のみ", []string{
+			"[download]  10.0% of 1.00GiB",
+			"[download] Got error: HTTP Error 503. Retrying fragment 11 (1/10)...",
+			"[download] Got error: HTTP Error 503. Retrying fragment 11 (2/10)...",
+		}, false},
+		{"進捗率が変わらない", []string{"[download]  10.0% of ~ 1.00GiB", "[download]  10.0% of ~ 1.02GiB"}, false},
+	}
+	for _, c := range cases {
+		w := newProgressWriter(io.Discard, nil)
+		w.observe(c.lines[0], start)
+		for i, line := range c.lines[1:] {
+			w.observe(line, start.Add(time.Duration(i+1)*time.Minute))
+		}
+		if advanced := w.lastSeen.After(start); advanced != c.advanced {
+			t.Errorf("%s: 進捗あり = %v, want %v", c.name, advanced, c.advanced)
+		}
+	}
+}
+
+func TestProgressWriterLines(t *testing.T) {
+	var lines []string
+	w := newProgressWriter(io.Discard, func(line string) { lines = append(lines, line) })
+	w.Write([]byte("[download]   1.0% of 10MiB\r[download]   1.0% of 10MiB\n[down"))
+	w.Write([]byte("load]   2.0% of 10MiB\n"))
+	if len(lines) != 2 || lines[1] != "[download]   2.0% of 10MiB" {
+		t.Errorf("lines = %q", lines)
+	}
+	if w.progress.Percent != 2 {
+		t.Errorf("progress = %+v, want 2%%", w.progress)
+	}
+}
+
+func TestIsPartialFile(t *testing.T) {
+	cases := map[string]bool{
+		"番組 - 第1話.mp4":            false,
+		"番組 - 第1話.mp4.part":       true,
+		"番組 - 第1話.f137.mp4.part":  true,
+		"番組 - 第1話.mp4.ytdl":       true,
+		"番組 - 第1話.temp":           true,
+		"番組 - 第1話.mp4.part-Frag3": true,
+		"番組 - 第1話.ja.srt":         false,
+	}
+	for name, want := range cases {
+		if got := isPartialFile(name); got != want {
+			t.Errorf("isPartialFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
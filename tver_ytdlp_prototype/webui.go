@@ -0,0 +1,286 @@
+// webui.go
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ブラウザ用の画面 (serve で / に配信)
+//
+//go:embed webui
+var webUIFiles embed.FS
+
+// SSEで進捗をまとめて送る間隔
+const sseInterval = 500 * time.Millisecond
+
+// 画面から編集できる設定ファイル (TVerRec本体と同じ書式)
+var editableLists = map[string]bool{
+	"keyword": true, // ダウンロード対象キーワード
+	"ignore":  true, // ダウンロード対象外番組
+}
+
+// 埋め込んだ画面を配信するハンドラー
+func webUIHandler() http.Handler {
+	root, err := fs.Sub(webUIFiles, "webui")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(root)
+}
+
+// 一覧に残っているジョブのコピー (statusが空なら全件)
+func (s *DownloadServer) jobSnapshot(status string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []Job{}
+	for _, id := range s.order {
+		if job := s.jobs[id]; status == "" || job.Status == status {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+// GET /api/events: ジョブ一覧の変更をServer-Sent Eventsで配信
+func (s *DownloadServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, errors.New("ストリーミングに対応していません"))
+		return
+	}
+
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		writeJSONError(w, http.StatusServiceUnavailable, errors.New("APIサーバーは停止中です"))
+		return
+	}
+	s.subscribers[ch] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		data, err := json.Marshal(map[string]any{"jobs": s.jobSnapshot("")})
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: jobs\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		// 進捗行ごとに送らないよう、間隔を空けてから次の変更を待つ
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(sseInterval):
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// 設定ファイルのパス (存在しなければ雛形のパスも返す)
+func (s *DownloadServer) listPaths(name string) (path, samplePath string) {
+	return filepath.Join(s.opts.ConfDir, name+".conf"),
+		filepath.Join(s.opts.SampleDir, name+".sample.conf")
+}
+
+// 雛形の置き場所の既定値 (設定ディレクトリと同じ階層のresources/sample、なければ実行ファイルからの相対位置)
+func defaultSampleDir(confDir string) string {
+	candidates := []string{filepath.Join(filepath.Dir(filepath.Clean(confDir)), "resources", "sample")}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(exe), "..", "resources", "sample"))
+	}
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return candidates[0]
+}
+
+// 設定ファイルの内容
+type listContent struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Sample  bool   `json:"sample"` // 設定ファイルがなく雛形を返した
+}
+
+// GET /api/lists/{name}: keyword.conf・ignore.confの内容
+func (s *DownloadServer) handleGetList(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !editableLists[name] {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("不明な設定ファイル: %s", name))
+		return
+	}
+
+	path, samplePath := s.listPaths(name)
+	list := listContent{Name: name}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		list.Sample = true
+		data, err = os.ReadFile(samplePath)
+		if errors.Is(err, os.ErrNotExist) {
+			data, err = nil, nil
+		}
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("設定ファイル読み込みエラー: %w", err))
+		return
+	}
+	list.Content = string(data)
+	writeJSON(w, http.StatusOK, list)
+}
+
+// PUT /api/lists/{name}: keyword.conf・ignore.confを書き換え
+func (s *DownloadServer) handlePutList(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !editableLists[name] {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("不明な設定ファイル: %s", name))
+		return
+	}
+
+	var list listContent
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("リクエスト解析エラー: %w", err))
+		return
+	}
+
+	path, _ := s.listPaths(name)
+	if err := os.MkdirAll(s.opts.ConfDir, 0755); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("設定ディレクトリ作成エラー: %w", err))
+		return
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(list.Content), 0644); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("設定ファイル書き込みエラー: %w", err))
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		writeJSONError(w, http.StatusInternalServerError, fmt.Errorf("設定ファイル書き込みエラー: %w", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, listContent{Name: name, Content: list.Content})
+}
+
+// 画面から変更できるダウンロード設定
+type serveSettings struct {
+	OutputDir       string `json:"output_dir"` // 表示のみ
+	Workers         int    `json:"workers"`    // 表示のみ
+	ContainerFormat string `json:"container_format"`
+	EmbedMetatag    bool   `json:"embed_metatag"`
+	WriteNFO        bool   `json:"write_nfo"`
+	SaveThumbnails  bool   `json:"save_thumbnails"`
+	EmbedThumbnail  bool   `json:"embed_thumbnail"`
+	WriteSubtitles  bool   `json:"write_subtitles"`
+	EmbedSubtitle   bool   `json:"embed_subtitle"`
+	SubtitleFormat  string `json:"subtitle_format"`
+	TimeoutSec      int    `json:"timeout_sec"`       // 0で無制限
+	StallTimeoutSec int    `json:"stall_timeout_sec"` // 0で無効
+	RepublishPolicy string `json:"republish_policy"`  // skip, redownload, replace
+
+	RedownloadNewVersion bool `json:"redownload_new_version"`
+}
+
+// 現在の設定 (s.muを保持して呼ぶ)
+func (s *DownloadServer) settingsLocked() serveSettings {
+	d := s.downloader
+	return serveSettings{
+		OutputDir:       d.OutputDir,
+		Workers:         s.opts.Workers,
+		ContainerFormat: d.ContainerFormat,
+		EmbedMetatag:    d.EmbedMetatag,
+		WriteNFO:        d.WriteNFO,
+		SaveThumbnails:  d.SaveThumbnails,
+		EmbedThumbnail:  d.EmbedThumbnail,
+		WriteSubtitles:  d.WriteSubtitles,
+		EmbedSubtitle:   d.EmbedSubtitle,
+		SubtitleFormat:  d.SubtitleFormat,
+		TimeoutSec:      int(d.Timeout / time.Second),
+		StallTimeoutSec: int(d.StallTimeout / time.Second),
+		RepublishPolicy: d.RepublishPolicy,
+
+		RedownloadNewVersion: d.RedownloadNewVersion,
+	}
+}
+
+// GET /api/settings: 現在のダウンロード設定
+func (s *DownloadServer) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	settings := s.settingsLocked()
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// PUT /api/settings: ダウンロード設定を変更 (次に開始するジョブから反映、再起動で元に戻る)
+func (s *DownloadServer) handlePutSettings(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings := s.settingsLocked()
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("リクエスト解析エラー: %w", err))
+		return
+	}
+	if !subtitleFormats[settings.SubtitleFormat] {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("不明な字幕形式: %s (srt または vtt を指定してください)", settings.SubtitleFormat))
+		return
+	}
+	if !republishPolicies[settings.RepublishPolicy] {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("不明なエピソードID変更時の扱い: %s (skip, redownload, replace のいずれかを指定してください)", settings.RepublishPolicy))
+		return
+	}
+	if settings.TimeoutSec < 0 || settings.StallTimeoutSec < 0 {
+		writeJSONError(w, http.StatusBadRequest, errors.New("タイムアウトには0以上の秒数を指定してください"))
+		return
+	}
+
+	updated := *s.downloader
+	updated.ContainerFormat = settings.ContainerFormat
+	updated.EmbedMetatag = settings.EmbedMetatag
+	updated.WriteNFO = settings.WriteNFO
+	updated.SaveThumbnails = settings.SaveThumbnails
+	updated.EmbedThumbnail = settings.EmbedThumbnail
+	updated.WriteSubtitles = settings.WriteSubtitles
+	updated.EmbedSubtitle = settings.EmbedSubtitle
+	updated.SubtitleFormat = settings.SubtitleFormat
+	updated.Timeout = time.Duration(settings.TimeoutSec) * time.Second
+	updated.StallTimeout = time.Duration(settings.StallTimeoutSec) * time.Second
+	updated.RepublishPolicy = settings.RepublishPolicy
+	updated.RedownloadNewVersion = settings.RedownloadNewVersion
+	if err := updated.ValidateContainer(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	*s.downloader = updated
+	writeJSON(w, http.StatusOK, s.settingsLocked())
+}
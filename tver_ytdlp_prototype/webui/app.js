@@ -0,0 +1,232 @@
+'use strict';
+
+const statusLabels = {
+	queued: '待機中',
+	running: 'ダウンロード中',
+	completed: '完了',
+	failed: '失敗',
+	canceled: '取消',
+	skipped: 'ダウンロード済',
+};
+
+// API呼び出し (エラー時はレスポンスのerrorを投げる)
+async function api(method, path, body) {
+	const options = { method, headers: {} };
+	if (body !== undefined) {
+		options.headers['Content-Type'] = 'application/json';
+		options.body = JSON.stringify(body);
+	}
+	const res = await fetch(path, options);
+	const data = await res.json();
+	if (!res.ok) {
+		throw new Error(data.error || res.statusText);
+	}
+	return data;
+}
+
+function showMessage(id, text, isError) {
+	const el = document.getElementById(id);
+	el.textContent = text;
+	el.classList.toggle('error', Boolean(isError));
+}
+
+function cell(text, className) {
+	const td = document.createElement('td');
+	td.textContent = text || '';
+	if (className) {
+		td.className = className;
+	}
+	return td;
+}
+
+function jobName(job) {
+	const name = [job.series_title, job.title].filter(Boolean).join(' ');
+	return name || job.url;
+}
+
+function formatTime(value) {
+	return value ? new Date(value).toLocaleString() : '';
+}
+
+// タブ切り替え
+document.querySelectorAll('nav button').forEach((button) => {
+	button.addEventListener('click', () => {
+		document.querySelectorAll('nav button, .tab').forEach((el) => el.classList.remove('active'));
+		button.classList.add('active');
+		document.getElementById(button.dataset.tab).classList.add('active');
+		if (button.dataset.tab === 'history') {
+			loadHistory();
+		}
+	});
+});
+
+// キュー
+function renderJobs(jobs) {
+	const tbody = document.getElementById('jobs');
+	tbody.replaceChildren();
+	// 実行中・待機中を上に、それ以外は新しい順
+	const active = jobs.filter((job) => job.status === 'running' || job.status === 'queued');
+	const finished = jobs.filter((job) => !active.includes(job)).reverse();
+	for (const job of active.concat(finished)) {
+		const tr = document.createElement('tr');
+		tr.append(cell(job.id));
+
+		const name = cell(jobName(job));
+		const detail = document.createElement('div');
+		detail.className = 'detail';
+		detail.textContent = job.error || job.media_path || job.last_line || '';
+		name.append(detail);
+		tr.append(name);
+
+		tr.append(cell(statusLabels[job.status] || job.status, 'status-' + job.status));
+
+		const progressCell = document.createElement('td');
+		if (job.status === 'running' || job.status === 'completed') {
+			const progress = document.createElement('progress');
+			progress.max = 100;
+			progress.value = job.progress;
+			progressCell.append(progress, ` ${job.progress.toFixed(1)}%`);
+		}
+		tr.append(progressCell);
+
+		const actionCell = document.createElement('td');
+		if (job.status === 'running' || job.status === 'queued') {
+			const button = document.createElement('button');
+			button.textContent = job.status === 'running' ? '中断' : '取消';
+			button.addEventListener('click', async () => {
+				try {
+					await api('DELETE', `/api/jobs/${job.id}`);
+				} catch (err) {
+					showMessage('enqueue-message', err.message, true);
+				}
+			});
+			actionCell.append(button);
+		}
+		tr.append(actionCell);
+		tbody.append(tr);
+	}
+}
+
+function connectEvents() {
+	const connection = document.getElementById('connection');
+	const events = new EventSource('/api/events');
+	events.addEventListener('jobs', (e) => {
+		renderJobs(JSON.parse(e.data).jobs);
+	});
+	events.onopen = () => {
+		connection.textContent = '接続中';
+		connection.className = 'online';
+	};
+	events.onerror = () => {
+		connection.textContent = '再接続中';
+		connection.className = 'offline';
+	};
+}
+
+document.getElementById('enqueue-form').addEventListener('submit', async (e) => {
+	e.preventDefault();
+	const body = { url: document.getElementById('enqueue-url').value.trim() };
+	const season = document.getElementById('enqueue-season').value.trim();
+	const from = Number(document.getElementById('enqueue-from').value);
+	const to = Number(document.getElementById('enqueue-to').value);
+	if (season) body.season = season;
+	if (from) body.from = from;
+	if (to) body.to = to;
+
+	showMessage('enqueue-message', '登録中...');
+	try {
+		const data = await api('POST', '/api/jobs', body);
+		showMessage('enqueue-message', `${data.jobs.length}件を登録しました`);
+		e.target.reset();
+	} catch (err) {
+		showMessage('enqueue-message', err.message, true);
+	}
+});
+
+// 履歴
+async function loadHistory() {
+	const params = new URLSearchParams({
+		q: document.getElementById('history-query').value,
+		status: document.getElementById('history-status').value,
+	});
+	const data = await api('GET', `/api/history?${params}`);
+	const tbody = document.getElementById('history-jobs');
+	tbody.replaceChildren();
+	for (const job of data.jobs) {
+		const tr = document.createElement('tr');
+		tr.append(
+			cell(formatTime(job.finished_at)),
+			cell(jobName(job)),
+			cell(statusLabels[job.status] || job.status, 'status-' + job.status),
+			cell(job.error || job.media_path, 'detail'),
+		);
+		tbody.append(tr);
+	}
+}
+
+document.getElementById('history-form').addEventListener('submit', (e) => {
+	e.preventDefault();
+	loadHistory();
+});
+
+// キーワード・除外リスト
+async function loadList(name) {
+	const data = await api('GET', `/api/lists/${name}`);
+	document.getElementById(`list-${name}`).value = data.content;
+	if (data.sample) {
+		showMessage('lists-message', `${name}.conf がないため雛形を表示しています (保存すると作成されます)`);
+	}
+}
+
+document.querySelectorAll('[data-save-list]').forEach((button) => {
+	button.addEventListener('click', async () => {
+		const name = button.dataset.saveList;
+		try {
+			await api('PUT', `/api/lists/${name}`, { content: document.getElementById(`list-${name}`).value });
+			showMessage('lists-message', `${name}.conf を保存しました`);
+		} catch (err) {
+			showMessage('lists-message', err.message, true);
+		}
+	});
+});
+
+// 設定
+const settingsForm = document.getElementById('settings-form');
+
+function fillSettings(settings) {
+	for (const [key, value] of Object.entries(settings)) {
+		const input = settingsForm.elements[key];
+		if (!input) continue;
+		if (input.type === 'checkbox') {
+			input.checked = value;
+		} else {
+			input.value = value;
+		}
+	}
+}
+
+settingsForm.addEventListener('submit', async (e) => {
+	e.preventDefault();
+	const settings = {};
+	for (const input of settingsForm.elements) {
+		if (!input.name || input.readOnly) continue;
+		if (input.type === 'checkbox') {
+			settings[input.name] = input.checked;
+		} else if (input.type === 'number') {
+			settings[input.name] = Number(input.value);
+		} else {
+			settings[input.name] = input.value;
+		}
+	}
+	try {
+		fillSettings(await api('PUT', '/api/settings', settings));
+		showMessage('settings-message', '設定を保存しました');
+	} catch (err) {
+		showMessage('settings-message', err.message, true);
+	}
+});
+
+connectEvents();
+loadList('keyword').catch((err) => showMessage('lists-message', err.message, true));
+loadList('ignore').catch((err) => showMessage('lists-message', err.message, true));
+api('GET', '/api/settings').then(fillSettings).catch((err) => showMessage('settings-message', err.message, true));
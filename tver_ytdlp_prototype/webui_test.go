@@ -0,0 +1,94 @@
+// webui_test.go
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerListsAndSettingsProtected(t *testing.T) {
+	s := newTestDownloadServer(t, "127.0.0.1:8080")
+	handler := s.Handler()
+	const host = "127.0.0.1:8080"
+
+	cases := []struct {
+		name, path, body string
+		headers          []string
+	}{
+		{"別サイトからのキーワード変更", "/api/lists/keyword", `{"content": "x"}`,
+			[]string{"Content-Type: application/json", "Origin: https://attacker.example"}},
+		{"JSON以外のキーワード変更", "/api/lists/keyword", `{"content": "x"}`,
+			[]string{"Content-Type: text/plain", "Origin: http://" + host}},
+		{"トークンなしのキーワード変更", "/api/lists/keyword", `{"content": "x"}`,
+			[]string{"Content-Type: application/json"}},
+		{"別サイトからの設定変更", "/api/settings", `{"write_nfo": true}`,
+			[]string{"Content-Type: application/json", "Sec-Fetch-Site: cross-site"}},
+	}
+	for _, c := range cases {
+		if rec := serveTestRequest(handler, "PUT", host, c.path, c.body, c.headers...); rec.Code/100 != 4 {
+			t.Errorf("%s: ステータス = %d", c.name, rec.Code)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.opts.ConfDir, "keyword.conf")); err == nil {
+		t.Error("拒否したリクエストで設定ファイルが書き換えられました")
+	}
+	if s.downloader.WriteNFO {
+		t.Error("拒否したリクエストで設定が変更されました")
+	}
+
+	// 同一オリジンからは変更できる
+	rec := serveTestRequest(handler, "PUT", host, "/api/lists/keyword", `{"content": "ドラマ\n"}`,
+		"Content-Type: application/json", "Origin: http://"+host)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("キーワード変更: ステータス = %d (%s)", rec.Code, rec.Body)
+	}
+	if data, _ := os.ReadFile(filepath.Join(s.opts.ConfDir, "keyword.conf")); string(data) != "ドラマ\n" {
+		t.Errorf("keyword.conf = %q", data)
+	}
+}
+
+func TestServerListSample(t *testing.T) {
+	s := newTestDownloadServer(t, "127.0.0.1:8080")
+	if err := os.MkdirAll(s.opts.SampleDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(s.opts.SampleDir, "ignore.sample.conf"), "# 雛形\n")
+
+	cases := []struct {
+		name   string
+		want   string
+		sample bool
+	}{
+		{"ignore", "# 雛形\n", true},
+		{"keyword", "", true},
+	}
+	for _, c := range cases {
+		rec := serveTestRequest(s.Handler(), "GET", "localhost:8080", "/api/lists/"+c.name, "")
+		var list listContent
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if list.Content != c.want || list.Sample != c.sample {
+			t.Errorf("%s: %+v", c.name, list)
+		}
+	}
+}
+
+func TestDefaultSampleDir(t *testing.T) {
+	root := t.TempDir()
+	sampleDir := filepath.Join(root, "resources", "sample")
+	if err := os.MkdirAll(sampleDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := defaultSampleDir(filepath.Join(root, "conf")); got != sampleDir {
+		t.Errorf("defaultSampleDir = %s, want %s", got, sampleDir)
+	}
+	// どこにも見つからなければ設定ディレクトリ基準のパスを返す
+	missing := filepath.Join(t.TempDir(), "conf")
+	if got, want := defaultSampleDir(missing), filepath.Join(filepath.Dir(missing), "resources", "sample"); got != want {
+		t.Errorf("defaultSampleDir = %s, want %s", got, want)
+	}
+}